@@ -10,6 +10,8 @@ import (
 type Node interface {
 	TokenLiteral() string // for debugging
 	String() string       // for debugging
+	Line() int            // source line the node starts on
+	Column() int          // source column the node's token starts on
 }
 
 // Statement type for Node
@@ -37,6 +39,22 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Line() int {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Line()
+	} else {
+		return 0
+	}
+}
+
+func (p *Program) Column() int {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Column()
+	} else {
+		return 0
+	}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -49,17 +67,29 @@ func (p *Program) String() string {
 
 // Let Statement Node
 type LetStatement struct {
-	Token token.Token // token.LET
+	Token token.Token // token.LET, or token.CONST for bindings that can't be reassigned
 	Name  *Identifier
 	Value Expression
 }
 
 func (ls *LetStatement) statementNode() {}
 
+func (ls *LetStatement) IsConst() bool {
+	return ls.Token.Type == token.CONST
+}
+
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Line() int {
+	return ls.Token.Line
+}
+
+func (ls *LetStatement) Column() int {
+	return ls.Token.Column
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -75,6 +105,84 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Multiple Let Statement Node
+// e.g. "let a = 1, b = a + 1;" binds each name in order
+type MultiLetStatement struct {
+	Token token.Token     // token.LET or token.CONST
+	Lets  []*LetStatement // One per binding, sharing Token
+}
+
+func (ms *MultiLetStatement) statementNode() {}
+
+func (ms *MultiLetStatement) TokenLiteral() string {
+	return ms.Token.Literal
+}
+
+func (ms *MultiLetStatement) Line() int {
+	return ms.Token.Line
+}
+
+func (ms *MultiLetStatement) Column() int {
+	return ms.Token.Column
+}
+
+func (ms *MultiLetStatement) String() string {
+	bindings := []string{}
+	for _, let := range ms.Lets {
+		bindings = append(bindings, let.Name.String()+" = "+let.Value.String())
+	}
+
+	return ms.TokenLiteral() + " " + strings.Join(bindings, ", ") + ";"
+}
+
+// Destructure Statement Node
+// e.g. "let [a, b = 0] = arr;" binds array elements, using defaults for missing ones
+type DestructureStatement struct {
+	Token    token.Token // token.LET
+	Names    []*Identifier
+	Defaults []Expression // nil entry if name has no default
+	Value    Expression
+}
+
+func (ds *DestructureStatement) statementNode() {}
+
+func (ds *DestructureStatement) TokenLiteral() string {
+	return ds.Token.Literal
+}
+
+func (ds *DestructureStatement) Line() int {
+	return ds.Token.Line
+}
+
+func (ds *DestructureStatement) Column() int {
+	return ds.Token.Column
+}
+
+func (ds *DestructureStatement) String() string {
+	var out bytes.Buffer
+
+	names := []string{}
+	for i, n := range ds.Names {
+		if ds.Defaults[i] != nil {
+			names = append(names, n.String()+" = "+ds.Defaults[i].String())
+		} else {
+			names = append(names, n.String())
+		}
+	}
+
+	out.WriteString(ds.TokenLiteral() + " ")
+	out.WriteString("[")
+	out.WriteString(strings.Join(names, ", "))
+	out.WriteString("] = ")
+
+	if ds.Value != nil {
+		out.WriteString(ds.Value.String())
+	}
+
+	out.WriteString(";")
+	return out.String()
+}
+
 // Return Statement Node
 type ReturnStatement struct {
 	Token token.Token // token.RETURN
@@ -87,6 +195,14 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Line() int {
+	return rs.Token.Line
+}
+
+func (rs *ReturnStatement) Column() int {
+	return rs.Token.Column
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -100,6 +216,121 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// Defer Statement Node
+// e.g. "defer print(x);" runs when the enclosing function returns
+type DeferStatement struct {
+	Token token.Token // token.DEFER
+	Value Expression
+}
+
+func (ds *DeferStatement) statementNode() {}
+
+func (ds *DeferStatement) TokenLiteral() string {
+	return ds.Token.Literal
+}
+
+func (ds *DeferStatement) Line() int {
+	return ds.Token.Line
+}
+
+func (ds *DeferStatement) Column() int {
+	return ds.Token.Column
+}
+
+func (ds *DeferStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ds.TokenLiteral() + " ")
+
+	if ds.Value != nil {
+		out.WriteString(ds.Value.String())
+	}
+
+	out.WriteString(";")
+	return out.String()
+}
+
+// While Statement Node
+// e.g. "while (i < 3) { let i = i + 1; }"
+type WhileStatement struct {
+	Token     token.Token // token.WHILE
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) Line() int {
+	return ws.Token.Line
+}
+
+func (ws *WhileStatement) Column() int {
+	return ws.Token.Column
+}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// Break Statement Node
+// e.g. "break;" exits the nearest loop
+type BreakStatement struct {
+	Token token.Token // token.BREAK
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Line() int {
+	return bs.Token.Line
+}
+
+func (bs *BreakStatement) Column() int {
+	return bs.Token.Column
+}
+
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// Continue Statement Node
+// e.g. "continue;" goes back to the condition of the nearest loop
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Line() int {
+	return cs.Token.Line
+}
+
+func (cs *ContinueStatement) Column() int {
+	return cs.Token.Column
+}
+
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
 // Expression Statement Node
 // Wrapper: Statement consists of 1 Expression
 // e.g. "x + 5;" is valid
@@ -114,6 +345,14 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Line() int {
+	return es.Token.Line
+}
+
+func (es *ExpressionStatement) Column() int {
+	return es.Token.Column
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -134,6 +373,14 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Line() int {
+	return bs.Token.Line
+}
+
+func (bs *BlockStatement) Column() int {
+	return bs.Token.Column
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -144,6 +391,30 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// Bad Statement Node
+// Marks a statement that couldn't be parsed, so the rest of the AST is kept
+type BadStatement struct {
+	Token token.Token // first token of statement
+}
+
+func (bs *BadStatement) statementNode() {}
+
+func (bs *BadStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BadStatement) Line() int {
+	return bs.Token.Line
+}
+
+func (bs *BadStatement) Column() int {
+	return bs.Token.Column
+}
+
+func (bs *BadStatement) String() string {
+	return "<bad statement>"
+}
+
 // Identifier Expression Node
 type Identifier struct {
 	Token token.Token // token.IDENT
@@ -156,6 +427,14 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Line() int {
+	return i.Token.Line
+}
+
+func (i *Identifier) Column() int {
+	return i.Token.Column
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -172,10 +451,42 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Line() int {
+	return il.Token.Line
+}
+
+func (il *IntegerLiteral) Column() int {
+	return il.Token.Column
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// Float Literal Expression Node
+type FloatLiteral struct {
+	Token token.Token // token.FLOAT
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Line() int {
+	return fl.Token.Line
+}
+
+func (fl *FloatLiteral) Column() int {
+	return fl.Token.Column
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // Prefix Expression Node
 type Prefix struct {
 	Token    token.Token // prefix token e.g. "!"
@@ -189,6 +500,14 @@ func (p *Prefix) TokenLiteral() string {
 	return p.Token.Literal
 }
 
+func (p *Prefix) Line() int {
+	return p.Token.Line
+}
+
+func (p *Prefix) Column() int {
+	return p.Token.Column
+}
+
 func (p *Prefix) String() string {
 	var out bytes.Buffer
 
@@ -214,6 +533,14 @@ func (i *Infix) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Infix) Line() int {
+	return i.Token.Line
+}
+
+func (i *Infix) Column() int {
+	return i.Token.Column
+}
+
 func (i *Infix) String() string {
 	var out bytes.Buffer
 
@@ -238,6 +565,14 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Line() int {
+	return b.Token.Line
+}
+
+func (b *Boolean) Column() int {
+	return b.Token.Column
+}
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -256,6 +591,14 @@ func (i *If) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *If) Line() int {
+	return i.Token.Line
+}
+
+func (i *If) Column() int {
+	return i.Token.Column
+}
+
 func (i *If) String() string {
 	var out bytes.Buffer
 
@@ -272,11 +615,87 @@ func (i *If) String() string {
 	return out.String()
 }
 
+// With Expression Node
+// e.g. "with (x = 1, y = x + 1) { x + y }" binds x and y for the block only
+type With struct {
+	Token  token.Token // token.WITH
+	Names  []*Identifier
+	Values []Expression // Values[i] can refer to Names[:i]
+	Body   *BlockStatement
+}
+
+func (w *With) expressionNode() {}
+
+func (w *With) TokenLiteral() string {
+	return w.Token.Literal
+}
+
+func (w *With) Line() int {
+	return w.Token.Line
+}
+
+func (w *With) Column() int {
+	return w.Token.Column
+}
+
+func (w *With) String() string {
+	var out bytes.Buffer
+
+	bindings := []string{}
+	for i, name := range w.Names {
+		bindings = append(bindings, name.String()+" = "+w.Values[i].String())
+	}
+
+	out.WriteString("with(")
+	out.WriteString(strings.Join(bindings, ", "))
+	out.WriteString(") ")
+	out.WriteString(w.Body.String())
+
+	return out.String()
+}
+
+// Try Expression Node
+// e.g. "try { int(s) } catch (e) { 0 }" evaluates the catch body with e bound to the message if the try body fails
+type Try struct {
+	Token   token.Token // token.TRY
+	Body    *BlockStatement
+	Name    *Identifier // Bound to the error message (a string) in Handler
+	Handler *BlockStatement
+}
+
+func (t *Try) expressionNode() {}
+
+func (t *Try) TokenLiteral() string {
+	return t.Token.Literal
+}
+
+func (t *Try) Line() int {
+	return t.Token.Line
+}
+
+func (t *Try) Column() int {
+	return t.Token.Column
+}
+
+func (t *Try) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(t.Body.String())
+	out.WriteString(" catch(")
+	out.WriteString(t.Name.String())
+	out.WriteString(") ")
+	out.WriteString(t.Handler.String())
+
+	return out.String()
+}
+
 // Function Expression Node
 type Function struct {
 	Token      token.Token // token.FUNCTION
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Name       string // Name bound by let statement (if any), so body can refer to itself
 }
 
 func (f *Function) expressionNode() {}
@@ -285,6 +704,14 @@ func (f *Function) TokenLiteral() string {
 	return f.Token.Literal
 }
 
+func (f *Function) Line() int {
+	return f.Token.Line
+}
+
+func (f *Function) Column() int {
+	return f.Token.Column
+}
+
 func (f *Function) String() string {
 	var out bytes.Buffer
 
@@ -303,6 +730,45 @@ func (f *Function) String() string {
 	return out.String()
 }
 
+// Macro Expression Node
+type MacroLiteral struct {
+	Token      token.Token // token.MACRO
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (m *MacroLiteral) expressionNode() {}
+
+func (m *MacroLiteral) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+func (m *MacroLiteral) Line() int {
+	return m.Token.Line
+}
+
+func (m *MacroLiteral) Column() int {
+	return m.Token.Column
+}
+
+func (m *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	parameters := []string{}
+	for _, p := range m.Parameters {
+		parameters = append(parameters, p.String())
+	}
+
+	out.WriteString(m.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(parameters, ", "))
+	out.WriteString(")")
+
+	out.WriteString(m.Body.String())
+
+	return out.String()
+}
+
 // Call Expression Node
 type Call struct {
 	Token     token.Token // token.LPAREN
@@ -316,6 +782,14 @@ func (c *Call) TokenLiteral() string {
 	return c.Token.Literal
 }
 
+func (c *Call) Line() int {
+	return c.Token.Line
+}
+
+func (c *Call) Column() int {
+	return c.Token.Column
+}
+
 func (c *Call) String() string {
 	var out bytes.Buffer
 
@@ -344,6 +818,14 @@ func (s *String) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *String) Line() int {
+	return s.Token.Line
+}
+
+func (s *String) Column() int {
+	return s.Token.Column
+}
+
 func (s *String) String() string {
 	return s.Token.Literal
 }
@@ -360,6 +842,14 @@ func (a *Array) TokenLiteral() string {
 	return a.Token.Literal
 }
 
+func (a *Array) Line() int {
+	return a.Token.Line
+}
+
+func (a *Array) Column() int {
+	return a.Token.Column
+}
+
 func (a *Array) String() string {
 	var out bytes.Buffer
 
@@ -388,6 +878,14 @@ func (i *Index) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Index) Line() int {
+	return i.Token.Line
+}
+
+func (i *Index) Column() int {
+	return i.Token.Column
+}
+
 func (i *Index) String() string {
 	var out bytes.Buffer
 
@@ -412,6 +910,14 @@ func (h *Hash) TokenLiteral() string {
 	return h.Token.Literal
 }
 
+func (h *Hash) Line() int {
+	return h.Token.Line
+}
+
+func (h *Hash) Column() int {
+	return h.Token.Column
+}
+
 func (h *Hash) String() string {
 	var out bytes.Buffer
 
@@ -0,0 +1,86 @@
+package ast
+
+// Returns the node to put in place of the given node
+type ModifierFunc func(Node) Node
+
+// Walk the tree depth-first, replacing each node with the modifier's result
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	case *ExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+	case *BlockStatement:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	case *LetStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *MultiLetStatement:
+		for i, let := range node.Lets {
+			node.Lets[i], _ = Modify(let, modifier).(*LetStatement)
+		}
+	case *DestructureStatement:
+		for i, d := range node.Defaults {
+			if d != nil {
+				node.Defaults[i], _ = Modify(d, modifier).(Expression)
+			}
+		}
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ReturnStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *DeferStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *Prefix:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *Infix:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *If:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+	case *With:
+		for i, v := range node.Values {
+			node.Values[i], _ = Modify(v, modifier).(Expression)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *Try:
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+		node.Handler, _ = Modify(node.Handler, modifier).(*BlockStatement)
+	case *Function:
+		for i, p := range node.Parameters {
+			node.Parameters[i], _ = Modify(p, modifier).(*Identifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *Call:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, a := range node.Arguments {
+			node.Arguments[i], _ = Modify(a, modifier).(Expression)
+		}
+	case *Array:
+		for i, e := range node.Elements {
+			node.Elements[i], _ = Modify(e, modifier).(Expression)
+		}
+	case *Index:
+		node.Array, _ = Modify(node.Array, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *Hash:
+		pairs := make(map[Expression]Expression)
+		for k, v := range node.Pairs {
+			key, _ := Modify(k, modifier).(Expression)
+			value, _ := Modify(v, modifier).(Expression)
+			pairs[key] = value
+		}
+		node.Pairs = pairs
+	}
+
+	return modifier(node)
+}
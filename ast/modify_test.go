@@ -0,0 +1,73 @@
+package ast
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	// Replace 1 with 2
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&Infix{Left: one(), Operator: "+", Right: two()}, &Infix{Left: two(), Operator: "+", Right: two()}},
+		{&Infix{Left: two(), Operator: "+", Right: one()}, &Infix{Left: two(), Operator: "+", Right: two()}},
+		{&Prefix{Operator: "-", Value: one()}, &Prefix{Operator: "-", Value: two()}},
+		{&Index{Array: one(), Index: one()}, &Index{Array: two(), Index: two()}},
+		{
+			&If{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&If{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{Value: one()}, &ReturnStatement{Value: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{
+			&Function{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&Function{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{
+			&Call{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), two()}},
+			&Call{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two()}},
+		},
+		{&Array{Elements: []Expression{one(), one()}}, &Array{Elements: []Expression{two(), two()}}},
+	}
+
+	for _, test := range tests {
+		modified := Modify(test.input, turnOneIntoTwo)
+		assert.Equal(t, test.expected, modified)
+	}
+
+	hash := &Hash{Pairs: map[Expression]Expression{one(): one(), two(): two()}}
+	Modify(hash, turnOneIntoTwo)
+
+	for key, value := range hash.Pairs {
+		assert.Equal(t, int64(2), key.(*IntegerLiteral).Value)
+		assert.Equal(t, int64(2), value.(*IntegerLiteral).Value)
+	}
+}
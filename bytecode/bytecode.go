@@ -1,6 +1,7 @@
 package bytecode
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 )
@@ -9,33 +10,47 @@ type Instructions []byte
 type Opcode byte
 
 const (
-	OpConstant      Opcode = iota // 1 operand: previous assigned number to constant
-	OpAdd                         // 0 operands
-	OpPop                         // 0 operands
-	OpSub                         // 0 operands
-	OpMul                         // 0 operands
-	OpDiv                         // 0 operands
-	OpTrue                        // 0 operands
-	OpFalse                       // 0 operands
-	OpEqual                       // 0 operands
-	OpNotEqual                    // 0 operands
-	OpGreater                     // 0 operands
-	OpMinus                       // 0 operands
-	OpBang                        // 0 operands
-	OpJumpNotTruthy               // 1 operand: jump offset if stack top is false, not null
-	OpJump                        // 1 operand: jump offset)
-	OpNull                        // 0 operands
-	OpGetGlobal                   // 1 operand: unique index of global binding
-	OpSetGlobal                   // 1 operand: unique index of global binding
-	OpArray                       // 1 operand: number of elements
-	OpHash                        // 1 operand: number of key + value elements
-	OpIndex                       // 0 operands
-	OpCall                        // 1 operand: number of arguments in call
-	OpReturnValue                 // 0 operands: return value at top of stack
-	OpReturnNothing               // 0 operands: return from current function (no value)
-	OpSetLocal                    // 1 operand: unique index of local binding
-	OpGetLocal                    // 1 operand: unique index of local binding
-	OpGetBuiltin                  // 1 operand: index of builtin function
+	OpConstant           Opcode = iota // 1 operand: previous assigned number to constant
+	OpAdd                              // 0 operands
+	OpPop                              // 0 operands
+	OpSub                              // 0 operands
+	OpMul                              // 0 operands
+	OpDiv                              // 0 operands
+	OpTrue                             // 0 operands
+	OpFalse                            // 0 operands
+	OpEqual                            // 0 operands
+	OpNotEqual                         // 0 operands
+	OpGreater                          // 0 operands
+	OpMinus                            // 0 operands
+	OpBang                             // 0 operands
+	OpJumpNotTruthy                    // 1 operand: jump offset if stack top is false, not null
+	OpJump                             // 1 operand: jump offset)
+	OpNull                             // 0 operands
+	OpGetGlobal                        // 1 operand: unique index of global binding
+	OpSetGlobal                        // 1 operand: unique index of global binding
+	OpArray                            // 1 operand: number of elements
+	OpHash                             // 1 operand: number of key + value elements
+	OpIndex                            // 0 operands
+	OpCall                             // 1 operand: number of arguments in call
+	OpReturnValue                      // 0 operands: return value at top of stack
+	OpReturnNothing                    // 0 operands: return from current function (no value)
+	OpSetLocal                         // 1 operand: unique index of local binding
+	OpGetLocal                         // 1 operand: unique index of local binding
+	OpGetBuiltin                       // 1 operand: index of builtin function
+	OpCurrentClosure                   // 0 operands: push function currently being executed
+	OpIn                               // 0 operands: check if item is in container
+	OpMod                              // 0 operands
+	OpGreaterEqual                     // 0 operands
+	OpJumpTruthyOrPop                  // 1 operand: jump offset if stack top is truthy (keeping it), else pop it
+	OpJumpNotTruthyOrPop               // 1 operand: jump offset if stack top isn't truthy (keeping it), else pop it
+	OpTailCall                         // 1 operand: number of arguments in call whose result is returned right away
+	OpClosure                          // 2 operands: constant index of compiled function, number of free variables on stack
+	OpGetFree                          // 1 operand: index of free variable of function currently being executed
+	OpEnterLoop                        // 0 operands: remember the stack height at the start of a loop
+	OpExitLoop                         // 0 operands: forget the stack height of the innermost loop
+	OpUnwindLoop                       // 0 operands: drop values pushed since the innermost loop started (before break/continue)
+	OpLess                             // 0 operands
+	OpLessEqual                        // 0 operands
 )
 
 type Definition struct {
@@ -44,33 +59,47 @@ type Definition struct {
 }
 
 var definitions = map[Opcode]*Definition{
-	OpConstant:      {"OpConstant", []int{2}},
-	OpAdd:           {"OpAdd", []int{}},
-	OpPop:           {"OpPop", []int{}},
-	OpSub:           {"OpSub", []int{}},
-	OpMul:           {"OpMul", []int{}},
-	OpDiv:           {"OpDiv", []int{}},
-	OpTrue:          {"OpTrue", []int{}},
-	OpFalse:         {"OpFalse", []int{}},
-	OpEqual:         {"OpEqual", []int{}},
-	OpNotEqual:      {"OpNotEqual", []int{}},
-	OpGreater:       {"OpGreater", []int{}},
-	OpMinus:         {"OpMinus", []int{}},
-	OpBang:          {"OpBang", []int{}},
-	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
-	OpJump:          {"OpJump", []int{2}},
-	OpNull:          {"OpNull", []int{}},
-	OpGetGlobal:     {"OpGetGlobal", []int{2}},
-	OpSetGlobal:     {"OpSetGlobal", []int{2}},
-	OpArray:         {"OpArray", []int{2}},
-	OpHash:          {"OpHash", []int{2}},
-	OpIndex:         {"OpIndex", []int{}},
-	OpCall:          {"OpCall", []int{1}},
-	OpReturnValue:   {"OpReturnValue", []int{}},
-	OpReturnNothing: {"OpReturnNothing", []int{}},
-	OpGetLocal:      {"OpGetLocal", []int{1}},
-	OpSetLocal:      {"OpSetLocal", []int{1}},
-	OpGetBuiltin:    {"OpGetBuiltin", []int{1}},
+	OpConstant:           {"OpConstant", []int{2}},
+	OpAdd:                {"OpAdd", []int{}},
+	OpPop:                {"OpPop", []int{}},
+	OpSub:                {"OpSub", []int{}},
+	OpMul:                {"OpMul", []int{}},
+	OpDiv:                {"OpDiv", []int{}},
+	OpTrue:               {"OpTrue", []int{}},
+	OpFalse:              {"OpFalse", []int{}},
+	OpEqual:              {"OpEqual", []int{}},
+	OpNotEqual:           {"OpNotEqual", []int{}},
+	OpGreater:            {"OpGreater", []int{}},
+	OpMinus:              {"OpMinus", []int{}},
+	OpBang:               {"OpBang", []int{}},
+	OpJumpNotTruthy:      {"OpJumpNotTruthy", []int{2}},
+	OpJump:               {"OpJump", []int{2}},
+	OpNull:               {"OpNull", []int{}},
+	OpGetGlobal:          {"OpGetGlobal", []int{2}},
+	OpSetGlobal:          {"OpSetGlobal", []int{2}},
+	OpArray:              {"OpArray", []int{2}},
+	OpHash:               {"OpHash", []int{2}},
+	OpIndex:              {"OpIndex", []int{}},
+	OpCall:               {"OpCall", []int{1}},
+	OpReturnValue:        {"OpReturnValue", []int{}},
+	OpReturnNothing:      {"OpReturnNothing", []int{}},
+	OpGetLocal:           {"OpGetLocal", []int{1}},
+	OpSetLocal:           {"OpSetLocal", []int{1}},
+	OpGetBuiltin:         {"OpGetBuiltin", []int{1}},
+	OpCurrentClosure:     {"OpCurrentClosure", []int{}},
+	OpIn:                 {"OpIn", []int{}},
+	OpMod:                {"OpMod", []int{}},
+	OpGreaterEqual:       {"OpGreaterEqual", []int{}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpTailCall:           {"OpTailCall", []int{1}},
+	OpClosure:            {"OpClosure", []int{2, 1}},
+	OpGetFree:            {"OpGetFree", []int{1}},
+	OpEnterLoop:          {"OpEnterLoop", []int{}},
+	OpExitLoop:           {"OpExitLoop", []int{}},
+	OpUnwindLoop:         {"OpUnwindLoop", []int{}},
+	OpLess:               {"OpLess", []int{}},
+	OpLessEqual:          {"OpLessEqual", []int{}},
 }
 
 // Make instruction from op and operands (Big Endian)
@@ -108,6 +137,60 @@ func Make(op Opcode, operands ...int) []byte {
 	return instruction
 }
 
+// Read operands of an instruction (ins starts after the opcode), and the number of bytes read
+func ReadOperands(definition *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(definition.OperandWidths))
+	offset := 0
+
+	for i, width := range definition.OperandWidths {
+		switch width {
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// Disassemble instructions, one per line (e.g. "0000 OpConstant 1")
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(ins); {
+		definition, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "%04d ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+
+		width := 0
+		for _, w := range definition.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			fmt.Fprintf(&out, "%04d ERROR: %s is missing operands\n", i, definition.Name)
+			break
+		}
+
+		operands, read := ReadOperands(definition, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s", i, definition.Name)
+		for _, operand := range operands {
+			fmt.Fprintf(&out, " %d", operand)
+		}
+		out.WriteString("\n")
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
 func ReadUint16(i Instructions) uint16 {
 	return binary.BigEndian.Uint16(i)
 }
@@ -26,6 +26,11 @@ func TestMake(t *testing.T) {
 			[]int{255},
 			[]byte{byte(OpGetLocal), 255},
 		},
+		{
+			OpClosure,
+			[]int{65534, 255},
+			[]byte{byte(OpClosure), 255, 254, 255},
+		},
 	}
 
 	for _, test := range tests {
@@ -38,3 +43,40 @@ func TestMake(t *testing.T) {
 		}
 	}
 }
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	ins := Instructions{}
+	for _, i := range instructions {
+		ins = append(ins, i...)
+	}
+
+	expected := "0000 OpAdd\n0001 OpGetLocal 1\n0003 OpConstant 2\n0006 OpConstant 65535\n0009 OpClosure 65535 255\n"
+	assert.Equal(t, expected, ins.String())
+
+	// Truncated instruction
+	assert.Equal(t, "0000 ERROR: OpConstant is missing operands\n", Instructions{byte(OpConstant), 1}.String())
+}
+
+func TestReadOperands(t *testing.T) {
+	definition, err := Lookup(byte(OpConstant))
+	assert.Nil(t, err)
+
+	operands, read := ReadOperands(definition, Make(OpConstant, 65534)[1:])
+	assert.Equal(t, []int{65534}, operands)
+	assert.Equal(t, 2, read)
+
+	definition, err = Lookup(byte(OpClosure))
+	assert.Nil(t, err)
+
+	operands, read = ReadOperands(definition, Make(OpClosure, 65534, 255)[1:])
+	assert.Equal(t, []int{65534, 255}, operands)
+	assert.Equal(t, 3, read)
+}
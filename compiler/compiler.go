@@ -14,6 +14,7 @@ var PRINT_COMPILER = false
 type Bytecode struct {
 	Instructions bytecode.Instructions // Instructions generated by compiler
 	Constants    []object.Object       // Constants evaluated by compiler
+	SourceMap    map[int]int           // Instruction offset --> source line
 }
 
 type EmittedInstruction struct {
@@ -28,6 +29,14 @@ type CompilationScope struct {
 	instructions            bytecode.Instructions // Generated bytecode
 	lastInstruction         EmittedInstruction    // Last instruction emitted
 	secondToLastInstruction EmittedInstruction    // Second to last instruction emitted
+	sourceMap               map[int]int           // Instruction offset --> source line
+	loops                   []*loop               // Loops being compiled (innermost last)
+}
+
+// Jump targets of a loop being compiled
+type loop struct {
+	conditionPosition int   // Where continue jumps to
+	breakPositions    []int // OpJumps emitted by break, patched to after the loop
 }
 
 // Translates AST to bytecode
@@ -36,6 +45,24 @@ type Compiler struct {
 	scopes      []CompilationScope // Scope stack
 	scopeIndex  int                // Top of scope stack
 	symbolTable *SymbolTable       // Store info about each identifier
+	line        int                // Source line of node being compiled
+	tailCalls   map[*ast.Call]bool // Calls whose result is returned right away by the enclosing function
+	folding     bool               // Precompute integer and string arithmetic on literals
+	lets        []declaration      // Names bound with let, in order, for unused variable warnings
+	shadows     map[shadow]bool    // Locals defined before a loop whose first let hasn't been compiled yet
+	binding     map[string]bool    // New globals whose let value is being compiled
+}
+
+// Local of a function that shadows an outer name of the same name
+type shadow struct {
+	table *SymbolTable
+	name  string
+}
+
+// Name bound with let, and the table it was defined in
+type declaration struct {
+	name  *ast.Identifier
+	table *SymbolTable
 }
 
 func BuildCompiler() *Compiler {
@@ -43,6 +70,7 @@ func BuildCompiler() *Compiler {
 		instructions:            bytecode.Instructions{},
 		lastInstruction:         EmittedInstruction{},
 		secondToLastInstruction: EmittedInstruction{},
+		sourceMap:               map[int]int{},
 	}
 
 	symbolTable := BuildSymbolTable()
@@ -55,6 +83,9 @@ func BuildCompiler() *Compiler {
 		scopes:      []CompilationScope{mainScope},
 		scopeIndex:  0,
 		symbolTable: symbolTable,
+		tailCalls:   map[*ast.Call]bool{},
+		shadows:     map[shadow]bool{},
+		binding:     map[string]bool{},
 	}
 }
 
@@ -65,6 +96,17 @@ func BuildStatefulCompiler(s *SymbolTable, constants []object.Object) *Compiler
 	return compiler
 }
 
+// Make redeclaring a name in the same scope a compile error (shadowing in a function is still allowed)
+func (c *Compiler) SetStrict(strict bool) {
+	c.symbolTable.SetStrict(strict)
+}
+
+// Precompute integer and string arithmetic on literals (e.g. "2 + 3 * 4" becomes the constant 14)
+// Off by default, so the bytecode follows the source
+func (c *Compiler) SetConstantFolding(folding bool) {
+	c.folding = folding
+}
+
 // Helper method to get instructions in current scope
 func (c *Compiler) currentInstructions() bytecode.Instructions {
 	return c.scopes[c.scopeIndex].instructions
@@ -75,6 +117,7 @@ func (c *Compiler) enterScope() {
 		instructions:            bytecode.Instructions{},
 		lastInstruction:         EmittedInstruction{},
 		secondToLastInstruction: EmittedInstruction{},
+		sourceMap:               map[int]int{},
 	}
 
 	c.scopes = append(c.scopes, scope)
@@ -96,6 +139,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 	if PRINT_COMPILER {
 		color.Green("Compile %T: %s", node, node.String())
 	}
+
+	// Instructions emitted for this node (after its children) belong to its line
+	if line := node.Line(); line > 0 {
+		outerLine := c.line
+		c.line = line
+		defer func() { c.line = outerLine }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, statement := range node.Statements {
@@ -117,7 +168,21 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
-		c.emit(bytecode.OpCall, len(node.Arguments))
+		if c.tailCalls[node] {
+			c.emit(bytecode.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(bytecode.OpCall, len(node.Arguments))
+		}
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macro definitions must be top-level let statements")
+	case *ast.DeferStatement:
+		return fmt.Errorf("defer is not supported by the compiler")
+	case *ast.DestructureStatement:
+		return fmt.Errorf("destructuring is not supported by the compiler")
+	case *ast.With:
+		return fmt.Errorf("with is not supported by the compiler")
+	case *ast.Try:
+		return fmt.Errorf("try is not supported by the compiler")
 	case *ast.ReturnStatement:
 		err := c.Compile(node.Value)
 		if err != nil {
@@ -127,10 +192,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.Function:
 		c.enterScope()
 
+		if node.Name != "" {
+			c.symbolTable.DefineFunctionName(node.Name)
+		}
+
 		for _, p := range node.Parameters {
 			c.symbolTable.Define(p.Value)
 		}
 
+		c.markTailCalls(node.Body, true)
 		err := c.Compile(node.Body)
 		if err != nil {
 			return err
@@ -147,10 +217,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		// Get number of local bindings
 		numLocals := c.symbolTable.numDefinitions
+		freeSymbols := c.symbolTable.FreeSymbols
+		sourceMap := c.scopes[c.scopeIndex].sourceMap
 		instructions := c.leaveScope()
 
-		compiledFunction := &object.CompiledFunction{instructions, numLocals, len(node.Parameters)}
-		c.emit(bytecode.OpConstant, c.addConstant(compiledFunction))
+		// Push captured values so the closure can take them off the stack
+		for _, symbol := range freeSymbols {
+			c.loadSymbol(symbol)
+		}
+
+		compiledFunction := &object.CompiledFunction{
+			Instructions:  instructions,
+			NumLocals:     numLocals,
+			NumParameters: len(node.Parameters),
+			SourceMap:     sourceMap,
+		}
+		c.emit(bytecode.OpClosure, c.addConstant(compiledFunction), len(freeSymbols))
 	case *ast.Index:
 		err := c.Compile(node.Array)
 		if err != nil {
@@ -196,13 +278,45 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		c.emit(bytecode.OpArray, len(node.Elements))
+	case *ast.MultiLetStatement:
+		for _, let := range node.Lets {
+			err := c.Compile(let)
+			if err != nil {
+				return err
+			}
+		}
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		if c.symbolTable.IsConst(node.Name.Value) {
+			return fmt.Errorf("cannot reassign constant %s", node.Name.Value)
+		}
+		// A local defined early for a loop (see shadowLoopLets) is first declared here
+		key := shadow{c.symbolTable, node.Name.Value}
+		declared := c.symbolTable.IsDeclared(node.Name.Value) && !c.shadows[key]
+		if c.symbolTable.Strict() && declared {
+			return fmt.Errorf("%s already declared", node.Name.Value)
+		}
+
+		// A new global is defined before its value, so functions in the value can call it (e.g. a memoized recursive function)
+		// The value itself still can't use it (see the Identifier case)
+		if c.symbolTable.Outer == nil && !c.symbolTable.IsDeclared(node.Name.Value) && !c.isBuiltin(node.Name.Value) {
+			c.defineLet(node)
+			c.binding[node.Name.Value] = true
+		}
+
+		// Otherwise the value is compiled first, so it sees the previous binding of the name
 		err := c.Compile(node.Value)
+		delete(c.binding, node.Name.Value)
 		if err != nil {
 			return err
 		}
 
+		if !declared {
+			c.lets = append(c.lets, declaration{node.Name, c.symbolTable})
+			delete(c.shadows, key)
+		}
+
+		symbol := c.defineLet(node)
+
 		if symbol.Scope == GlobalScope {
 			c.emit(bytecode.OpSetGlobal, symbol.Index)
 		} else {
@@ -211,18 +325,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 
-		// Throw a compile-time error if identifier doesn't exist
-		if !ok {
+		// Throw a compile-time error if identifier doesn't exist (or is a global whose let value this is)
+		if !ok || (symbol.Scope == GlobalScope && c.scopeIndex == 0 && c.binding[node.Value]) {
 			return fmt.Errorf("undefined variable %s", node.Value)
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(bytecode.OpGetGlobal, symbol.Index)
-		} else if symbol.Scope == LocalScope {
-			c.emit(bytecode.OpGetLocal, symbol.Index)
-		} else if symbol.Scope == BuiltinScope {
-			c.emit(bytecode.OpGetBuiltin, symbol.Index)
-		}
+		c.loadSymbol(symbol)
 	case *ast.ExpressionStatement:
 		err := c.Compile(node.Expression)
 		if err != nil {
@@ -236,6 +344,56 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 		}
+	case *ast.WhileStatement:
+		c.shadowLoopLets(node.Body)
+		c.emit(bytecode.OpEnterLoop)
+		conditionPosition := len(c.currentInstructions())
+
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+
+		// 9999 is a placeholder offset (will backpatch)
+		jumpNotTruthyPosition := c.emit(bytecode.OpJumpNotTruthy, 9999)
+
+		l := &loop{conditionPosition: conditionPosition}
+		c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, l)
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		loops := c.scopes[c.scopeIndex].loops
+		c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+
+		// Back to the condition
+		c.emit(bytecode.OpJump, conditionPosition)
+
+		afterBodyPosition := len(c.currentInstructions())
+		c.replaceInstructionOperand(jumpNotTruthyPosition, afterBodyPosition)
+		for _, position := range l.breakPositions {
+			c.replaceInstructionOperand(position, afterBodyPosition)
+		}
+		c.emit(bytecode.OpExitLoop)
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("break outside loop")
+		}
+
+		// 9999 is a placeholder offset (will backpatch)
+		c.emit(bytecode.OpUnwindLoop)
+		l.breakPositions = append(l.breakPositions, c.emit(bytecode.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("continue outside loop")
+		}
+
+		c.emit(bytecode.OpUnwindLoop)
+		c.emit(bytecode.OpJump, l.conditionPosition)
 	case *ast.If:
 		err := c.Compile(node.Condition)
 
@@ -248,20 +406,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		jumpNotTruthyPosition := c.emit(bytecode.OpJumpNotTruthy, 9999)
 
 		// Compile Consequence
-		err = c.Compile(node.Consequence)
+		err = c.compileBlockValue(node.Consequence)
 		if err != nil {
 			return err
 		}
 
-		// Remove the OpPop immediately after the Consequence
-		if c.scopes[c.scopeIndex].lastInstruction.Opcode == bytecode.OpPop {
-			lastInstruction := c.scopes[c.scopeIndex].lastInstruction
-			newInstructions := c.currentInstructions()[:lastInstruction.Position]
-
-			c.scopes[c.scopeIndex].instructions = newInstructions
-			c.scopes[c.scopeIndex].lastInstruction = c.scopes[c.scopeIndex].secondToLastInstruction
-		}
-
 		// 9999 is a placeholder offset (will backpatch)
 		// Save the position of OpJump instruction
 		jumpPosition := c.emit(bytecode.OpJump, 9999)
@@ -274,25 +423,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Alternative == nil {
 			c.emit(bytecode.OpNull)
 		} else {
-			err := c.Compile(node.Alternative)
+			err := c.compileBlockValue(node.Alternative)
 			if err != nil {
 				return err
 			}
-
-			// Remove the OpPop immediately after the Alternative
-			if c.scopes[c.scopeIndex].lastInstruction.Opcode == bytecode.OpPop {
-				lastInstruction := c.scopes[c.scopeIndex].lastInstruction
-				newInstructions := c.currentInstructions()[:lastInstruction.Position]
-
-				c.scopes[c.scopeIndex].instructions = newInstructions
-				c.scopes[c.scopeIndex].lastInstruction = c.scopes[c.scopeIndex].secondToLastInstruction
-			}
 		}
 
 		// Replace OpJump operand
 		afterAlternativePosition := len(c.currentInstructions())
 		c.replaceInstructionOperand(jumpPosition, afterAlternativePosition)
 	case *ast.Prefix:
+		if value, ok := foldBoolean(node); ok {
+			c.emitBoolean(value)
+			return nil
+		}
+		if c.folding {
+			if value, ok := foldConstant(node); ok {
+				c.emit(bytecode.OpConstant, c.addConstant(value))
+				return nil
+			}
+		}
+
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
@@ -307,19 +458,38 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return fmt.Errorf("unknown operator: %s", node.Operator)
 		}
 	case *ast.Infix:
-		// Special case for < (turn into >)
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
+		if value, ok := foldBoolean(node); ok {
+			c.emitBoolean(value)
+			return nil
+		}
+		if c.folding {
+			if value, ok := foldConstant(node); ok {
+				c.emit(bytecode.OpConstant, c.addConstant(value))
+				return nil
+			}
+		}
+
+		// Short-circuit: left is kept as the result if it decides it, else right is
+		if node.Operator == "&&" || node.Operator == "||" {
+			err := c.Compile(node.Left)
 			if err != nil {
 				return err
 			}
 
-			err = c.Compile(node.Left)
+			// 9999 is a placeholder offset (will backpatch)
+			var jumpPosition int
+			if node.Operator == "&&" {
+				jumpPosition = c.emit(bytecode.OpJumpNotTruthyOrPop, 9999)
+			} else {
+				jumpPosition = c.emit(bytecode.OpJumpTruthyOrPop, 9999)
+			}
+
+			err = c.Compile(node.Right)
 			if err != nil {
 				return err
 			}
 
-			c.emit(bytecode.OpGreater)
+			c.replaceInstructionOperand(jumpPosition, len(c.currentInstructions()))
 			return nil
 		}
 
@@ -342,8 +512,21 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(bytecode.OpMul)
 		case "/":
 			c.emit(bytecode.OpDiv)
+		case "%":
+			c.emit(bytecode.OpMod)
 		case ">":
 			c.emit(bytecode.OpGreater)
+		case ">=":
+			c.emit(bytecode.OpGreaterEqual)
+		case "<":
+			c.emit(bytecode.OpLess)
+		case "<=":
+			c.emit(bytecode.OpLessEqual)
+		case "in":
+			c.emit(bytecode.OpIn)
+		case "not in":
+			c.emit(bytecode.OpIn)
+			c.emit(bytecode.OpBang)
 		case "==":
 			c.emit(bytecode.OpEqual)
 		case "!=":
@@ -354,12 +537,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(bytecode.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(bytecode.OpConstant, c.addConstant(float))
 	case *ast.Boolean:
-		if node.Value {
-			c.emit(bytecode.OpTrue)
-		} else {
-			c.emit(bytecode.OpFalse)
-		}
+		c.emitBoolean(node.Value)
 	case *ast.String:
 		str := &object.String{Value: node.Value}
 		c.emit(bytecode.OpConstant, c.addConstant(str))
@@ -372,9 +554,173 @@ func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+	}
+}
+
+// Get a copy of the constant pool built up so far (changing it doesn't affect the compiler)
+func (c *Compiler) Constants() []object.Object {
+	return append([]object.Object{}, c.constants...)
+}
+
+// Get a snapshot of the symbol table of the scope being compiled (the global one after compilation)
+// Defining names in it doesn't affect the compiler
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable.Clone()
+}
+
+// Get warnings about names bound with let but never referenced, in the order they were bound
+// e.g. "line 3, column 5: unused variable x"
+func (c *Compiler) Warnings() []string {
+	warnings := []string{}
+	for _, let := range c.lets {
+		if !let.table.IsUsed(let.name.Value) {
+			warnings = append(warnings, fmt.Sprintf("line %d, column %d: unused variable %s",
+				let.name.Line(), let.name.Column(), let.name.Value))
+		}
+	}
+	return warnings
+}
+
+// Helper method to find calls in tail position of a function body (tail is false for blocks that don't end the body)
+func (c *Compiler) markTailCalls(block *ast.BlockStatement, tail bool) {
+	for i, statement := range block.Statements {
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			c.markTailExpression(statement.Value, true)
+		case *ast.ExpressionStatement:
+			c.markTailExpression(statement.Expression, tail && i == len(block.Statements)-1)
+		case *ast.WhileStatement:
+			c.markTailCalls(statement.Body, false)
+		}
+	}
+}
+
+// Helper method to find calls in tail position of an expression
+func (c *Compiler) markTailExpression(expression ast.Expression, tail bool) {
+	switch expression := expression.(type) {
+	case *ast.Call:
+		if tail {
+			c.tailCalls[expression] = true
+		}
+	case *ast.If:
+		c.markTailCalls(expression.Consequence, tail)
+		if expression.Alternative != nil {
+			c.markTailCalls(expression.Alternative, tail)
+		}
+	}
+}
+
+// Helper method to push the value bound to a symbol
+func (c *Compiler) loadSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(bytecode.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(bytecode.OpGetLocal, symbol.Index)
+	case BuiltinScope:
+		c.emit(bytecode.OpGetBuiltin, symbol.Index)
+	case FreeScope:
+		c.emit(bytecode.OpGetFree, symbol.Index)
+	case FunctionScope:
+		c.emit(bytecode.OpCurrentClosure)
 	}
 }
 
+// Helper method for lets in a loop body (in a function) that shadow an outer name
+// The local has to exist before the loop, so the condition and earlier statements see it on later iterations
+// It starts as a copy of the outer value, which is what they see before the let first runs
+func (c *Compiler) shadowLoopLets(body *ast.BlockStatement) {
+	if c.symbolTable.Outer == nil {
+		return
+	}
+
+	for _, name := range letNames(body) {
+		if c.symbolTable.IsDeclared(name) {
+			continue
+		}
+
+		outer, ok := c.symbolTable.Resolve(name)
+		if !ok {
+			continue
+		}
+
+		c.loadSymbol(outer)
+		symbol := c.symbolTable.Define(name)
+		c.emit(bytecode.OpSetLocal, symbol.Index)
+		c.shadows[shadow{c.symbolTable, name}] = true
+	}
+}
+
+// Helper function to get the names bound by lets in a block, including nested if and while bodies (not functions)
+func letNames(block *ast.BlockStatement) []string {
+	names := []string{}
+	for _, statement := range block.Statements {
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			names = append(names, statement.Name.Value)
+		case *ast.MultiLetStatement:
+			for _, let := range statement.Lets {
+				names = append(names, let.Name.Value)
+			}
+		case *ast.WhileStatement:
+			names = append(names, letNames(statement.Body)...)
+		case *ast.ExpressionStatement:
+			if i, ok := statement.Expression.(*ast.If); ok {
+				names = append(names, letNames(i.Consequence)...)
+				if i.Alternative != nil {
+					names = append(names, letNames(i.Alternative)...)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Helper method to compile an if body so it leaves exactly one value on the stack
+// That's the value of its last expression statement (its OpPop is removed), or null if it ends in anything else
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	start := len(c.currentInstructions())
+
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+
+	lastInstruction := c.scopes[c.scopeIndex].lastInstruction
+	if lastInstruction.Opcode != bytecode.OpPop || lastInstruction.Position < start {
+		c.emit(bytecode.OpNull)
+		return nil
+	}
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:lastInstruction.Position]
+	c.scopes[c.scopeIndex].lastInstruction = c.scopes[c.scopeIndex].secondToLastInstruction
+	return nil
+}
+
+// Helper method to check if name refers to a builtin in the current scope
+func (c *Compiler) isBuiltin(name string) bool {
+	symbol, ok := c.symbolTable.Resolve(name)
+	return ok && symbol.Scope == BuiltinScope
+}
+
+// Helper method to define the name bound by a let (defining it again gives the same symbol)
+func (c *Compiler) defineLet(node *ast.LetStatement) Symbol {
+	if node.IsConst() {
+		return c.symbolTable.DefineConst(node.Name.Value)
+	}
+	return c.symbolTable.Define(node.Name.Value)
+}
+
+// Helper method to get the innermost loop of the current scope (nil if there is none)
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
 // Helper method to replace an instruction's operand
 func (c *Compiler) replaceInstructionOperand(opPosition int, operand int) {
 	op := bytecode.Opcode(c.currentInstructions()[opPosition])
@@ -414,9 +760,122 @@ func (c *Compiler) emit(op bytecode.Opcode, operands ...int) int {
 	instruction := bytecode.Make(op, operands...)
 	position := c.addInstruction(instruction)
 	c.setLastInstruction(op, position)
+	c.scopes[c.scopeIndex].sourceMap[position] = c.line
 	return position // Returns starting position of newly emitted instruction
 }
 
+// Helper method to emit OpTrue or OpFalse
+func (c *Compiler) emitBoolean(value bool) {
+	if value {
+		c.emit(bytecode.OpTrue)
+	} else {
+		c.emit(bytecode.OpFalse)
+	}
+}
+
+// Helper function to evaluate integer and string arithmetic made only of literals (e.g. "2 + 3 * 4") at compile time
+// Anything the VM would fail on (e.g. division by zero) isn't folded, so the error still happens at run time
+func foldConstant(node ast.Expression) (object.Object, bool) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}, true
+	case *ast.String:
+		return &object.String{Value: node.Value}, true
+	case *ast.Prefix:
+		value, ok := foldConstant(node.Value)
+		integer, isInteger := value.(*object.Integer)
+		if !ok || !isInteger || node.Operator != "-" {
+			return nil, false
+		}
+
+		return &object.Integer{Value: -integer.Value}, true
+	case *ast.Infix:
+		left, ok := foldConstant(node.Left)
+		if !ok {
+			return nil, false
+		}
+
+		right, ok := foldConstant(node.Right)
+		if !ok {
+			return nil, false
+		}
+
+		switch left := left.(type) {
+		case *object.Integer:
+			right, ok := right.(*object.Integer)
+			if !ok {
+				return nil, false
+			}
+			return foldIntegers(left.Value, node.Operator, right.Value)
+		case *object.String:
+			right, ok := right.(*object.String)
+			if !ok || node.Operator != "+" {
+				return nil, false
+			}
+			return &object.String{Value: left.Value + right.Value}, true
+		}
+	}
+
+	return nil, false
+}
+
+// Helper function for folding arithmetic on two integers (same results as the VM)
+func foldIntegers(left int64, operator string, right int64) (object.Object, bool) {
+	switch {
+	case operator == "+":
+		return &object.Integer{Value: left + right}, true
+	case operator == "-":
+		return &object.Integer{Value: left - right}, true
+	case operator == "*":
+		return &object.Integer{Value: left * right}, true
+	case operator == "/" && right != 0:
+		return &object.Integer{Value: left / right}, true
+	case operator == "%" && right != 0:
+		return &object.Integer{Value: left % right}, true
+	default:
+		return nil, false
+	}
+}
+
+// Helper function to evaluate expressions made only of boolean literals (e.g. "!true") at compile time
+// Anything else (identifiers, calls, ...) isn't folded, so no side effects are skipped
+func foldBoolean(node ast.Expression) (bool, bool) {
+	switch node := node.(type) {
+	case *ast.Boolean:
+		return node.Value, true
+	case *ast.Prefix:
+		if node.Operator != "!" {
+			return false, false
+		}
+
+		value, ok := foldBoolean(node.Value)
+		return !value, ok
+	case *ast.Infix:
+		left, ok := foldBoolean(node.Left)
+		if !ok {
+			return false, false
+		}
+
+		right, ok := foldBoolean(node.Right)
+		if !ok {
+			return false, false
+		}
+
+		switch node.Operator {
+		case "==":
+			return left == right, true
+		case "!=":
+			return left != right, true
+		case "&&":
+			return left && right, true
+		case "||":
+			return left || right, true
+		}
+	}
+
+	return false, false
+}
+
 // Helper method to set last instruction and second to last instruction
 func (c *Compiler) setLastInstruction(op bytecode.Opcode, position int) {
 	c.scopes[c.scopeIndex].secondToLastInstruction = c.scopes[c.scopeIndex].lastInstruction
@@ -8,6 +8,7 @@ import (
 	"go_interpreter/object"
 	"go_interpreter/parser"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -49,6 +50,16 @@ func TestIntegerArithmetic(t *testing.T) {
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
+		{
+			"3 % 2",
+			[]interface{}{3, 2},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpMod),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
 		{
 			"3 - 2",
 			[]interface{}{3, 2},
@@ -103,11 +114,31 @@ func TestBoolean(t *testing.T) {
 		},
 		{
 			"1 < 2",
-			[]interface{}{2, 1},
+			[]interface{}{1, 2},
 			[]bytecode.Instructions{
 				bytecode.Make(bytecode.OpConstant, 0),
 				bytecode.Make(bytecode.OpConstant, 1),
-				bytecode.Make(bytecode.OpGreater),
+				bytecode.Make(bytecode.OpLess),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"1 <= 2",
+			[]interface{}{1, 2},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpLessEqual),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"1 >= 2",
+			[]interface{}{1, 2},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpGreaterEqual),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
@@ -134,12 +165,122 @@ func TestBoolean(t *testing.T) {
 		{
 			"!true",
 			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpFalse),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+}
+
+func TestShortCircuit(t *testing.T) {
+	tests := []testCase{
+		{
+			"1 && 2; 3",
+			[]interface{}{1, 2, 3},
+			[]bytecode.Instructions{
+				// 0000
+				bytecode.Make(bytecode.OpConstant, 0),
+				// 0003
+				bytecode.Make(bytecode.OpJumpNotTruthyOrPop, 9),
+				// 0006
+				bytecode.Make(bytecode.OpConstant, 1),
+				// 0009
+				bytecode.Make(bytecode.OpPop),
+				// 0010
+				bytecode.Make(bytecode.OpConstant, 2),
+				// 0013
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"1 || 2",
+			[]interface{}{1, 2},
+			[]bytecode.Instructions{
+				// 0000
+				bytecode.Make(bytecode.OpConstant, 0),
+				// 0003
+				bytecode.Make(bytecode.OpJumpTruthyOrPop, 9),
+				// 0006
+				bytecode.Make(bytecode.OpConstant, 1),
+				// 0009
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+}
+
+func TestBooleanFolding(t *testing.T) {
+	tests := []testCase{
+		{
+			"!!false",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpFalse),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"!true == false",
+			[]interface{}{},
 			[]bytecode.Instructions{
 				bytecode.Make(bytecode.OpTrue),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"true != !false",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpFalse),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			// Not only boolean literals, so not folded
+			"!5",
+			[]interface{}{5},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
 				bytecode.Make(bytecode.OpBang),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
+		{
+			"let x = true; !x",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpTrue),
+				bytecode.Make(bytecode.OpSetGlobal, 0),
+				bytecode.Make(bytecode.OpGetGlobal, 0),
+				bytecode.Make(bytecode.OpBang),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"true && !false || false",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpTrue),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"true == (1 > 2)",
+			[]interface{}{1, 2},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpTrue),
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpGreater),
+				bytecode.Make(bytecode.OpEqual),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
 	}
 
 	testCompiler(t, tests)
@@ -180,6 +321,115 @@ func TestConditional(t *testing.T) {
 	testCompiler(t, tests)
 }
 
+func TestWhile(t *testing.T) {
+	tests := []testCase{
+		{
+			"let i = 0; while (i < 2) { let i = i + 1; }",
+			[]interface{}{0, 2, 1},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),       // 0000
+				bytecode.Make(bytecode.OpSetGlobal, 0),      // 0003
+				bytecode.Make(bytecode.OpEnterLoop),         // 0006
+				bytecode.Make(bytecode.OpGetGlobal, 0),      // 0007
+				bytecode.Make(bytecode.OpConstant, 1),       // 0010
+				bytecode.Make(bytecode.OpLess),              // 0013
+				bytecode.Make(bytecode.OpJumpNotTruthy, 30), // 0014
+				bytecode.Make(bytecode.OpGetGlobal, 0),      // 0017
+				bytecode.Make(bytecode.OpConstant, 2),       // 0020
+				bytecode.Make(bytecode.OpAdd),               // 0023
+				bytecode.Make(bytecode.OpSetGlobal, 0),      // 0024 (Same index as before)
+				bytecode.Make(bytecode.OpJump, 7),           // 0027 (Back to the condition)
+				bytecode.Make(bytecode.OpExitLoop),          // 0030
+			},
+		},
+		{
+			"while (false) { }",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpEnterLoop),        // 0000
+				bytecode.Make(bytecode.OpFalse),            // 0001
+				bytecode.Make(bytecode.OpJumpNotTruthy, 8), // 0002
+				bytecode.Make(bytecode.OpJump, 1),          // 0005
+				bytecode.Make(bytecode.OpExitLoop),         // 0008
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+}
+
+func TestWith(t *testing.T) {
+	err := BuildCompiler().Compile(parse("with (x = 1) { x }"))
+	if err == nil {
+		t.Fatalf("Expected compiler error for with")
+	}
+	assert.Equal(t, "with is not supported by the compiler", err.Error())
+}
+
+func TestTry(t *testing.T) {
+	err := BuildCompiler().Compile(parse("try { 1 } catch (e) { 2 }"))
+	if err == nil {
+		t.Fatalf("Expected compiler error for try")
+	}
+	assert.Equal(t, "try is not supported by the compiler", err.Error())
+}
+
+func TestBreakContinue(t *testing.T) {
+	tests := []testCase{
+		{
+			"while (true) { break; continue; }",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpEnterLoop),         // 0000
+				bytecode.Make(bytecode.OpTrue),              // 0001
+				bytecode.Make(bytecode.OpJumpNotTruthy, 16), // 0002
+				bytecode.Make(bytecode.OpUnwindLoop),        // 0005
+				bytecode.Make(bytecode.OpJump, 16),          // 0006 (break: after the loop)
+				bytecode.Make(bytecode.OpUnwindLoop),        // 0009
+				bytecode.Make(bytecode.OpJump, 1),           // 0010 (continue: back to the condition)
+				bytecode.Make(bytecode.OpJump, 1),           // 0013
+				bytecode.Make(bytecode.OpExitLoop),          // 0016
+			},
+		},
+		{
+			"while (true) { if (true) { break; } }",
+			[]interface{}{},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpEnterLoop),         // 0000
+				bytecode.Make(bytecode.OpTrue),              // 0001
+				bytecode.Make(bytecode.OpJumpNotTruthy, 22), // 0002
+				bytecode.Make(bytecode.OpTrue),              // 0005
+				bytecode.Make(bytecode.OpJumpNotTruthy, 17), // 0006
+				bytecode.Make(bytecode.OpUnwindLoop),        // 0009
+				bytecode.Make(bytecode.OpJump, 22),          // 0010 (break: after the loop)
+				bytecode.Make(bytecode.OpNull),              // 0013 (the if body has no value)
+				bytecode.Make(bytecode.OpJump, 18),          // 0014
+				bytecode.Make(bytecode.OpNull),              // 0017
+				bytecode.Make(bytecode.OpPop),               // 0018
+				bytecode.Make(bytecode.OpJump, 1),           // 0019
+				bytecode.Make(bytecode.OpExitLoop),          // 0022
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+
+	errors := map[string]string{
+		"break;":                               "break outside loop",
+		"if (true) { continue; }":              "continue outside loop",
+		"while (true) { fn() { break; }; }":    "break outside loop",
+		"while (true) { fn() { continue; }; }": "continue outside loop",
+	}
+
+	for input, expected := range errors {
+		err := BuildCompiler().Compile(parse(input))
+		if err == nil {
+			t.Fatalf("Expected compiler error for %s", input)
+		}
+		assert.Equal(t, expected, err.Error(), input)
+	}
+}
+
 func TestGlobalLet(t *testing.T) {
 	tests := []testCase{
 		{
@@ -294,6 +544,36 @@ func TestHash(t *testing.T) {
 	testCompiler(t, tests)
 }
 
+func TestIn(t *testing.T) {
+	tests := []testCase{
+		{
+			"1 in [1]",
+			[]interface{}{1, 1},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpArray, 1),
+				bytecode.Make(bytecode.OpIn),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"1 not in [1]",
+			[]interface{}{1, 1},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpArray, 1),
+				bytecode.Make(bytecode.OpIn),
+				bytecode.Make(bytecode.OpBang),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+}
+
 func TestIndex(t *testing.T) {
 	tests := []testCase{
 		{
@@ -343,7 +623,7 @@ func TestFunction(t *testing.T) {
 				},
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 2),
+				bytecode.Make(bytecode.OpClosure, 2, 0),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
@@ -360,7 +640,7 @@ func TestFunction(t *testing.T) {
 				},
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 2),
+				bytecode.Make(bytecode.OpClosure, 2, 0),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
@@ -377,7 +657,7 @@ func TestFunction(t *testing.T) {
 				},
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 2),
+				bytecode.Make(bytecode.OpClosure, 2, 0),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
@@ -389,7 +669,7 @@ func TestFunction(t *testing.T) {
 				},
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpClosure, 0, 0),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
@@ -410,7 +690,7 @@ func TestFunctionCall(t *testing.T) {
 				},
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpClosure, 1, 0),
 				bytecode.Make(bytecode.OpCall, 0),
 				bytecode.Make(bytecode.OpPop),
 			},
@@ -424,7 +704,7 @@ func TestFunctionCall(t *testing.T) {
 				24,
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpClosure, 0, 0),
 				bytecode.Make(bytecode.OpSetGlobal, 0),
 				bytecode.Make(bytecode.OpGetGlobal, 0),
 				bytecode.Make(bytecode.OpConstant, 1),
@@ -451,7 +731,7 @@ func TestLocal(t *testing.T) {
 				},
 			},
 			[]bytecode.Instructions{
-				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpClosure, 1, 0),
 				bytecode.Make(bytecode.OpPop),
 			},
 		},
@@ -460,6 +740,326 @@ func TestLocal(t *testing.T) {
 	testCompiler(t, tests)
 }
 
+func TestRecursiveFunction(t *testing.T) {
+	tests := []testCase{
+		{
+			"let f = fn() { f() };",
+			[]interface{}{
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpCurrentClosure),
+					bytecode.Make(bytecode.OpTailCall, 0),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+			},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpClosure, 0, 0),
+				bytecode.Make(bytecode.OpSetGlobal, 0),
+			},
+		},
+		{
+			"let wrapper = fn() { let f = fn() { f() }; f() };",
+			[]interface{}{
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpCurrentClosure),
+					bytecode.Make(bytecode.OpTailCall, 0),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpClosure, 0, 0),
+					bytecode.Make(bytecode.OpSetLocal, 0),
+					bytecode.Make(bytecode.OpGetLocal, 0),
+					bytecode.Make(bytecode.OpTailCall, 0),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+			},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpClosure, 1, 0),
+				bytecode.Make(bytecode.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+}
+
+func TestTailCall(t *testing.T) {
+	// Calls in the function body in order, e.g. "OpCall OpTailCall"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(n) { f(n) }", "OpTailCall"},
+		{"let f = fn(n) { return f(n); }", "OpTailCall"},
+		{"let f = fn(n) { n + f(n) }", "OpCall"},
+		{"let f = fn(n) { f(n); 1 }", "OpCall"},
+		{"let f = fn(n) { let x = f(n); x }", "OpCall"},
+		{"let f = fn(n) { f(f(n)) }", "OpCall OpTailCall"},
+		{"let f = fn(n) { if (f(n)) { f(n) } else { len([]) } }", "OpCall OpTailCall OpTailCall"},
+		{"let f = fn(n) { if (n) { return f(n); }; f(n); 1 }", "OpTailCall OpCall"},
+		{"let f = fn(n) { if (n) { f(n) }; 1 }", "OpCall"},
+		{"let f = fn(n) { while (n) { f(n); return f(n); } }", "OpCall OpTailCall"},
+		{"let f = fn(n) { [f(n)] }", "OpCall"},
+	}
+
+	for _, test := range tests {
+		c := BuildCompiler()
+		err := c.Compile(parse(test.input))
+		assert.Nil(t, err, test.input)
+
+		fn := c.Constants()[len(c.Constants())-1].(*object.CompiledFunction)
+		calls := []string{}
+		for _, line := range strings.Split(fn.Disassemble(), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 && (fields[1] == "OpCall" || fields[1] == "OpTailCall") {
+				calls = append(calls, fields[1])
+			}
+		}
+		assert.Equal(t, test.expected, strings.Join(calls, " "), test.input)
+	}
+
+	// Calls outside functions are never tail calls
+	c := BuildCompiler()
+	assert.Nil(t, c.Compile(parse("len([])")))
+	assert.Contains(t, c.Bytecode().Instructions.String(), "OpCall 1")
+}
+
+func TestClosure(t *testing.T) {
+	tests := []testCase{
+		{
+			"fn(a) { fn(b) { a + b } }",
+			[]interface{}{
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpGetFree, 0),
+					bytecode.Make(bytecode.OpGetLocal, 0),
+					bytecode.Make(bytecode.OpAdd),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpGetLocal, 0),
+					bytecode.Make(bytecode.OpClosure, 0, 1),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+			},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpClosure, 1, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"let g = 1; fn(a) { fn(b) { fn(c) { g + a + b + c } } }",
+			[]interface{}{
+				1,
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpGetGlobal, 0),
+					bytecode.Make(bytecode.OpGetFree, 0),
+					bytecode.Make(bytecode.OpAdd),
+					bytecode.Make(bytecode.OpGetFree, 1),
+					bytecode.Make(bytecode.OpAdd),
+					bytecode.Make(bytecode.OpGetLocal, 0),
+					bytecode.Make(bytecode.OpAdd),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpGetFree, 0),
+					bytecode.Make(bytecode.OpGetLocal, 0),
+					bytecode.Make(bytecode.OpClosure, 1, 2),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpGetLocal, 0),
+					bytecode.Make(bytecode.OpClosure, 2, 1),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+			},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpSetGlobal, 0),
+				bytecode.Make(bytecode.OpClosure, 3, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+}
+
+func TestUndefinedVariable(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x + 1", "undefined variable x"},
+		{"let y = y;", "undefined variable y"},
+		{"let y = [1, y];", "undefined variable y"},
+		{"fn() { z }", "undefined variable z"},
+		{"fn(a) { fn() { a + b } }", "undefined variable b"},
+		{"fn() { let a = 1; }; a", "undefined variable a"},
+	}
+
+	for _, test := range tests {
+		err := BuildCompiler().Compile(parse(test.input))
+		if err == nil {
+			t.Fatalf("Expected compiler error for %s", test.input)
+		}
+		assert.Equal(t, test.expected, err.Error(), test.input)
+	}
+
+	// Builtins don't need to be defined
+	assert.Nil(t, BuildCompiler().Compile(parse("len([1]) + 1")))
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let used = 1;\nlet unused = 2;\nused + 1", []string{"line 2, column 5: unused variable unused"}},
+		{"let x = 1; x", []string{}},
+		// Re-binding a name uses its previous value
+		{"let i = 0; let i = i + 1;", []string{}},
+		{"let f = fn(a) { let b = a; let c = 1; c }; f(1)", []string{"line 1, column 21: unused variable b"}},
+		// Captured by a closure
+		{"let f = fn() { let x = 1; fn() { x } }; f", []string{}},
+		{"let a = 1, b = a;", []string{"line 1, column 12: unused variable b"}},
+		{"const limit = 10;", []string{"line 1, column 7: unused variable limit"}},
+	}
+
+	for _, test := range tests {
+		c := BuildCompiler()
+		err := c.Compile(parse(test.input))
+		assert.Nil(t, err, test.input)
+		assert.Equal(t, test.expected, c.Warnings(), test.input)
+	}
+}
+
+func TestNestedMacroDefinition(t *testing.T) {
+	program := parse("fn() { let m = macro(x) { x }; }")
+
+	compiler := BuildCompiler()
+	err := compiler.Compile(program)
+	if err == nil {
+		t.Fatalf("Expected compiler error")
+	}
+
+	assert.Equal(t, "macro definitions must be top-level let statements", err.Error())
+}
+
+func TestConst(t *testing.T) {
+	tests := []testCase{
+		{
+			"const one = 1; one;",
+			[]interface{}{1},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpSetGlobal, 0),
+				bytecode.Make(bytecode.OpGetGlobal, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+	}
+
+	testCompiler(t, tests)
+
+	errors := []string{
+		"const x = 1; let x = 2;",
+		"const x = 1; const x = 2;",
+		"fn() { const x = 1; let x = 2; }",
+	}
+
+	for _, input := range errors {
+		err := BuildCompiler().Compile(parse(input))
+		if err == nil {
+			t.Fatalf("Expected compiler error for %s", input)
+		}
+		assert.Equal(t, "cannot reassign constant x", err.Error(), input)
+	}
+
+	// let can be redefined, and constants can be shadowed inside functions
+	valid := []string{
+		"let x = 1; let x = 2;",
+		"const x = 1; fn() { let x = 2; }",
+	}
+
+	for _, input := range valid {
+		err := BuildCompiler().Compile(parse(input))
+		assert.Nil(t, err, input)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	errors := []string{
+		"let x = 1; let x = 2;",
+		"fn() { let x = 1; let x = 2; }",
+		"let x = 0; fn() { while (x < 2) { let x = x + 1; let x = 2; } }",
+	}
+
+	for _, input := range errors {
+		c := BuildCompiler()
+		c.SetStrict(true)
+		err := c.Compile(parse(input))
+		if err == nil {
+			t.Fatalf("Expected compiler error for %s", input)
+		}
+		assert.Equal(t, "x already declared", err.Error(), input)
+	}
+
+	// Shadowing in a function (and builtins) is always allowed
+	valid := []string{
+		"let x = 1; fn() { let x = 2; }",
+		"let x = 1; fn(y) { let x = y; }",
+		// The local a loop's let shadows with is defined before the loop, but declared by the let
+		"let x = 0; fn() { while (x < 2) { let x = x + 1; } }",
+		"let len = 1;",
+	}
+
+	for _, input := range valid {
+		c := BuildCompiler()
+		c.SetStrict(true)
+		err := c.Compile(parse(input))
+		assert.Nil(t, err, input)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	c := BuildCompiler()
+	err := c.Compile(parse(`let x = 1; let greet = fn(name) { let y = "hi " + name; y }; greet("x");`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []Symbol{{"x", GlobalScope, 0}, {"greet", GlobalScope, 1}}, c.SymbolTable().Symbols())
+
+	constants := c.Constants()
+	assert.Equal(t, 4, len(constants))
+	assert.Equal(t, "1", constants[0].Inspect())
+	assert.IsType(t, &object.CompiledFunction{}, constants[2])
+
+	// Both are copies
+	constants[0] = &object.Integer{Value: 99}
+	assert.Equal(t, "1", c.Constants()[0].Inspect())
+	assert.Equal(t, "1", c.Bytecode().Constants[0].Inspect())
+
+	c.SymbolTable().Define("z")
+	_, ok := c.SymbolTable().Resolve("z")
+	assert.False(t, ok)
+}
+
+func TestInspectCompiledFunction(t *testing.T) {
+	c := BuildCompiler()
+	err := c.Compile(parse("fn(a, b) { let c = a + b; c }"))
+	assert.Nil(t, err)
+
+	fn := c.Constants()[0]
+	assert.Equal(t, "CompiledFunction[2 params, 3 locals]", fn.Inspect())
+
+	expected := `0000 OpGetLocal 0
+0002 OpGetLocal 1
+0004 OpAdd
+0005 OpSetLocal 2
+0007 OpGetLocal 2
+0009 OpReturnValue
+`
+	assert.Equal(t, expected, fn.(*object.CompiledFunction).Disassemble())
+}
+
 func TestCompilerScope(t *testing.T) {
 	c := BuildCompiler()
 	assert.Equal(t, 0, c.scopeIndex)
@@ -512,6 +1112,27 @@ func TestBuiltin(t *testing.T) {
 	testCompiler(t, tests)
 }
 
+func TestSourceMap(t *testing.T) {
+	compiler := BuildCompiler()
+	err := compiler.Compile(parse("1 + 2;\n3;"))
+	assert.Nil(t, err)
+
+	// 0000 OpConstant 0, 0003 OpConstant 1, 0006 OpAdd, 0007 OpPop,
+	// 0008 OpConstant 2, 0011 OpPop
+	expected := map[int]int{0: 1, 3: 1, 6: 1, 7: 1, 8: 2, 11: 2}
+	assert.Equal(t, expected, compiler.Bytecode().SourceMap)
+
+	compiler = BuildCompiler()
+	err = compiler.Compile(parse("fn() {\n  5\n}"))
+	assert.Nil(t, err)
+
+	function, ok := compiler.Bytecode().Constants[1].(*object.CompiledFunction)
+	assert.True(t, ok)
+
+	// 0000 OpConstant 0, 0003 OpReturnValue
+	assert.Equal(t, map[int]int{0: 2, 3: 2}, function.SourceMap)
+}
+
 // Helper method to parse input string
 func parse(input string) *ast.Program {
 	l := lexer.BuildLexer(input)
@@ -519,6 +1140,91 @@ func parse(input string) *ast.Program {
 	return p.ParseProgram()
 }
 
+func TestConstantFolding(t *testing.T) {
+	tests := []testCase{
+		{
+			"2 + 3 * 4",
+			[]interface{}{14},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"-(7 % 4) - 10 / 2",
+			[]interface{}{-8},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			`"a" + "b" + "c"`,
+			[]interface{}{"abc"},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		// Only the constant part of an expression is folded
+		{
+			"let x = 1; x + 2 * 3",
+			[]interface{}{1, 6},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpSetGlobal, 0),
+				bytecode.Make(bytecode.OpGetGlobal, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpAdd),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		// Division by zero is left for the VM to report
+		{
+			"1 / 0",
+			[]interface{}{1, 0},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpConstant, 0),
+				bytecode.Make(bytecode.OpConstant, 1),
+				bytecode.Make(bytecode.OpDiv),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+		{
+			"fn() { 1 + 1 }",
+			[]interface{}{
+				2,
+				[]bytecode.Instructions{
+					bytecode.Make(bytecode.OpConstant, 0),
+					bytecode.Make(bytecode.OpReturnValue),
+				},
+			},
+			[]bytecode.Instructions{
+				bytecode.Make(bytecode.OpClosure, 1, 0),
+				bytecode.Make(bytecode.OpPop),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		compiler := BuildCompiler()
+		compiler.SetConstantFolding(true)
+		err := compiler.Compile(parse(test.input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+		testInstructions(t, test.expectedInstructions, bytecode.Instructions)
+		testConstants(t, test.expectedConstants, bytecode.Constants)
+	}
+
+	// Off by default
+	compiler := BuildCompiler()
+	assert.Nil(t, compiler.Compile(parse("2 + 3")))
+	assert.Len(t, compiler.Constants(), 2)
+}
+
 // Helper method to test compiler
 func testCompiler(t *testing.T, tests []testCase) {
 	for _, test := range tests {
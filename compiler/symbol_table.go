@@ -1,12 +1,16 @@
 package compiler
 
+import "sort"
+
 // Differentiate between different scopes for symbols
 type SymbolScope string
 
 const (
-	GlobalScope  SymbolScope = "GLOBAL"
-	LocalScope   SymbolScope = "LOCAL"
-	BuiltinScope SymbolScope = "BUILTIN"
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FunctionScope SymbolScope = "FUNCTION"
+	FreeScope     SymbolScope = "FREE"
 )
 
 // Stores Name, Scope, and Index for a given symbol
@@ -19,23 +23,58 @@ type Symbol struct {
 // Associate string identifiers with scope and unique number
 type SymbolTable struct {
 	Outer          *SymbolTable
+	FreeSymbols    []Symbol // Symbols of enclosing functions used here, in order of their free indices
 	store          map[string]Symbol
 	numDefinitions int
+	consts         map[string]bool // Names defined with "const" in this table
+	used           map[string]bool // Globals or locals of this table that have been resolved
+	strict         bool            // Redefining a name in the same table is an error
 }
 
 func BuildSymbolTable() *SymbolTable {
 	s := make(map[string]Symbol)
-	return &SymbolTable{store: s}
+	return &SymbolTable{store: s, consts: make(map[string]bool), used: make(map[string]bool)}
 }
 
 func BuildInnerSymbolTable(outer *SymbolTable) *SymbolTable {
 	inner := BuildSymbolTable()
 	inner.Outer = outer
+	inner.strict = outer.strict
 	return inner
 }
 
+// Copy this table, so names defined in the copy don't affect it (outer tables are shared)
+func (s *SymbolTable) Clone() *SymbolTable {
+	clone := &SymbolTable{
+		Outer:          s.Outer,
+		FreeSymbols:    append([]Symbol{}, s.FreeSymbols...),
+		store:          make(map[string]Symbol, len(s.store)),
+		numDefinitions: s.numDefinitions,
+		consts:         make(map[string]bool, len(s.consts)),
+		used:           make(map[string]bool, len(s.used)),
+		strict:         s.strict,
+	}
+
+	for name, symbol := range s.store {
+		clone.store[name] = symbol
+	}
+	for name := range s.consts {
+		clone.consts[name] = true
+	}
+	for name := range s.used {
+		clone.used[name] = true
+	}
+	return clone
+}
+
 // Create and store a symbol from an identifier
+// Redefining a name in the same scope reuses its index (e.g. "let i = i + 1;" in a loop)
 func (s *SymbolTable) Define(name string) Symbol {
+	existing, ok := s.store[name]
+	if ok && (existing.Scope == GlobalScope || existing.Scope == LocalScope) {
+		return existing
+	}
+
 	symbol := Symbol{Name: name, Index: s.numDefinitions}
 
 	// Set scope
@@ -53,21 +92,89 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// Define a symbol that can't be redefined in this table
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	s.consts[name] = true
+	return s.Define(name)
+}
+
+// Check if name was defined with DefineConst in this table (inner tables may shadow it)
+func (s *SymbolTable) IsConst(name string) bool {
+	return s.consts[name]
+}
+
+// Turn strict mode on or off (inner tables built afterwards inherit it)
+func (s *SymbolTable) SetStrict(strict bool) {
+	s.strict = strict
+}
+
+func (s *SymbolTable) Strict() bool {
+	return s.strict
+}
+
+// Check if name is a global or local defined in this table (not an outer one)
+func (s *SymbolTable) IsDeclared(name string) bool {
+	symbol, ok := s.store[name]
+	return ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope)
+}
+
+// Get copies of the globals or locals defined in this table (not an outer one), ordered by index
+func (s *SymbolTable) Symbols() []Symbol {
+	symbols := []Symbol{}
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Index < symbols[j].Index
+	})
+	return symbols
+}
+
+// Check if a global or local defined in this table has been resolved (i.e. referenced) since it was defined
+func (s *SymbolTable) IsUsed(name string) bool {
+	return s.used[name]
+}
+
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
 	s.store[name] = symbol
 	return symbol
 }
 
+// Name of the function being compiled (refers to itself, not a local)
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Capture a local (or free) symbol of an enclosing function as a free variable of this one
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
 // Retrieve a symbol for an identifier
+// Locals of enclosing functions become free variables of this one (globals and builtins are shared)
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
-	obj, ok := s.store[name]
+	symbol, ok := s.store[name]
+	if ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		s.used[name] = true
+	}
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
 
-	// Check outer environment if exists
-	if !ok && s.Outer != nil {
-		obj, ok := s.Outer.Resolve(name)
-		return obj, ok
-	} else {
-		return obj, ok
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
 	}
+
+	return s.defineFree(symbol), true
 }
@@ -21,6 +21,31 @@ func TestDefine(t *testing.T) {
 	}
 }
 
+func TestRedefine(t *testing.T) {
+	global := BuildSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	a := global.Define("a")
+	if a != (Symbol{"a", GlobalScope, 0}) {
+		t.Fatalf("a is wrong (redefine)")
+	}
+
+	local := BuildInnerSymbolTable(global)
+	local.Define("a")
+	a = local.Define("a")
+	if a != (Symbol{"a", LocalScope, 0}) {
+		t.Fatalf("a is wrong (redefine local)")
+	}
+
+	// Builtins can be shadowed by a new binding
+	global.DefineBuiltin(0, "len")
+	l := global.Define("len")
+	if l != (Symbol{"len", GlobalScope, 2}) {
+		t.Fatalf("len is wrong (shadow builtin)")
+	}
+}
+
 func TestResolveGlobal(t *testing.T) {
 	global := BuildSymbolTable()
 	global.Define("a")
@@ -70,3 +95,96 @@ func TestResolveLocal(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveFree(t *testing.T) {
+	global := BuildSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	first := BuildInnerSymbolTable(global)
+	first.Define("b")
+
+	second := BuildInnerSymbolTable(first)
+	second.Define("c")
+
+	expected := []Symbol{
+		{"a", GlobalScope, 0},
+		{"len", BuiltinScope, 0},
+		{"c", LocalScope, 0},
+		{"b", FreeScope, 0},
+	}
+
+	for _, e := range expected {
+		result, ok := second.Resolve(e.Name)
+		if !ok {
+			t.Fatalf("%s not resolvable", e.Name)
+		}
+
+		if result != e {
+			t.Fatalf("resolve error for %s: %v", e.Name, result)
+		}
+	}
+
+	// Only locals of enclosing functions are captured, once each
+	second.Resolve("b")
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0] != (Symbol{"b", LocalScope, 0}) {
+		t.Fatalf("free symbols are wrong: %v", second.FreeSymbols)
+	}
+
+	if _, ok := second.Resolve("d"); ok {
+		t.Fatalf("d shouldn't be resolvable")
+	}
+}
+
+func TestIsUsed(t *testing.T) {
+	global := BuildSymbolTable()
+	global.Define("a")
+	global.Define("b")
+	local := BuildInnerSymbolTable(global)
+	local.Define("c")
+
+	local.Resolve("a")
+	local.Resolve("c")
+	if !global.IsUsed("a") || global.IsUsed("b") || !local.IsUsed("c") {
+		t.Fatalf("usage is wrong")
+	}
+}
+
+func TestClone(t *testing.T) {
+	global := BuildSymbolTable()
+	global.Define("a")
+
+	clone := global.Clone()
+	clone.DefineConst("b")
+	if global.IsDeclared("b") || global.IsConst("b") || !clone.IsDeclared("a") {
+		t.Fatalf("clone isn't independent")
+	}
+
+	// Indices carry on from the original
+	if symbol := clone.Define("c"); symbol.Index != 2 {
+		t.Fatalf("expected index 2, got %d", symbol.Index)
+	}
+	if symbol := global.Define("c"); symbol.Index != 1 {
+		t.Fatalf("expected index 1, got %d", symbol.Index)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	global := BuildSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("b")
+	global.Define("a")
+	global.Define("b")
+
+	symbols := global.Symbols()
+	if len(symbols) != 2 || symbols[0] != (Symbol{"b", GlobalScope, 0}) || symbols[1] != (Symbol{"a", GlobalScope, 1}) {
+		t.Fatalf("symbols are wrong: %v", symbols)
+	}
+
+	local := BuildInnerSymbolTable(global)
+	local.Define("c")
+	symbols = local.Symbols()
+	if len(symbols) != 1 || symbols[0] != (Symbol{"c", LocalScope, 0}) {
+		t.Fatalf("local symbols are wrong: %v", symbols)
+	}
+}
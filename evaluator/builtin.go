@@ -2,11 +2,68 @@ package evaluator
 
 import "go_interpreter/object"
 
-var builtins = map[string]*object.BuiltIn{
-	"len":   object.GetBuiltin("len"),
-	"first": object.GetBuiltin("first"),
-	"last":  object.GetBuiltin("last"),
-	"tail":  object.GetBuiltin("tail"),
-	"push":  object.GetBuiltin("push"),
-	"print": object.GetBuiltin("print"),
+// Builtins shared with the VM (object.Builtins), plus the ones only the evaluator supports
+var builtins = buildBuiltins()
+
+func buildBuiltins() map[string]*object.BuiltIn {
+	b := make(map[string]*object.BuiltIn)
+	for _, def := range object.Builtins {
+		b[def.Name] = def.Builtin
+	}
+
+	b["panic"] = &object.BuiltIn{
+		Function:  panicBuiltin,
+		MinArgs:   1,
+		MaxArgs:   1,
+		Name:      "panic",
+		Signature: "panic(x)",
+		Doc:       "Unwind calls with x until recovered by recover() in a deferred expression",
+	}
+
+	return b
+}
+
+// Special forms the evaluator handles itself: they aren't values, but help describes them like builtins
+var specialForms = map[string]*object.BuiltIn{
+	"recover": {
+		Name:      "recover",
+		Signature: "recover()",
+		Doc:       "Stop the panic being unwound and get its value (null if there is none), in a deferred expression",
+	},
+	"quote": {
+		Name:      "quote",
+		Signature: "quote(x)",
+		Doc:       "Unevaluated x as a quote (parts inside unquote(...) are evaluated)",
+	},
+}
+
+// help also knows the builtins and special forms only the evaluator supports
+// (set in init, since helpBuiltin reads builtins)
+func init() {
+	help := *builtins["help"]
+	help.Function = helpBuiltin
+	builtins["help"] = &help
+}
+
+// Signature and description of a builtin or special form
+func helpBuiltin(args ...object.Object) object.Object {
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return NewError("argument to `help` must be string")
+	}
+
+	builtin, ok := builtins[name.Value]
+	if !ok {
+		builtin, ok = specialForms[name.Value]
+	}
+	if !ok {
+		return NewError("no builtin named `%s`", name.Value)
+	}
+
+	return &object.String{Value: builtin.Signature + ": " + builtin.Doc}
+}
+
+// Start unwinding calls with the given value (only the evaluator supports recover)
+func panicBuiltin(args ...object.Object) object.Object {
+	return &object.Panic{Value: args[0]}
 }
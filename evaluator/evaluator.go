@@ -5,20 +5,49 @@ import (
 	"github.com/fatih/color"
 	"go_interpreter/ast"
 	"go_interpreter/object"
+	"strings"
 )
 
 var PRINT_EVAL = false
 
+var PROFILE_EVAL = false        // Count node evaluations per source line
+var LineProfile = map[int]int{} // Source line --> number of node evaluations
+
+// Clear counts collected while PROFILE_EVAL is set
+func ResetProfile() {
+	LineProfile = map[int]int{}
+}
+
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if node == nil {
+		return NewError("cannot evaluate missing node")
+	}
+
 	if PRINT_EVAL {
 		color.Green("EVAL %T: evaluator.Eval(%s)", node, node.String())
 	}
+
+	if PROFILE_EVAL {
+		LineProfile[node.Line()]++
+	}
+
+	result := evalNode(node, env)
+
+	// The innermost node an error comes from gives its position
+	if err, ok := result.(*object.Error); ok && err.Line == 0 {
+		err.Line, err.Column = node.Line(), node.Column()
+	}
+	return result
+}
+
+// Helper method for evaluating each type of node
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -28,74 +57,150 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return evalBoolean(node.Value)
 	case *ast.Prefix:
 		value := Eval(node.Value, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 		return evalPrefix(node.Operator, value)
 	case *ast.Infix:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 
+		// Short-circuit: right is only evaluated if left doesn't decide the result
+		if node.Operator == "&&" || node.Operator == "||" {
+			if isTrue(left) == (node.Operator == "||") {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
 
 		return evalInfix(left, node.Operator, right)
 	case *ast.If:
 		return evalIf(node, env)
+	case *ast.WhileStatement:
+		return evalWhile(node, env)
+	case *ast.With:
+		return evalWith(node, env)
+	case *ast.Try:
+		return evalTry(node, env)
+	case *ast.BreakStatement:
+		return &object.Break{}
+	case *ast.ContinueStatement:
+		return &object.Continue{}
 	case *ast.ReturnStatement:
 		value := Eval(node.Value, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 		return &object.Return{Value: value}
+	case *ast.DeferStatement:
+		if !env.Defer(node.Value) {
+			return NewError("defer outside function")
+		}
+		return NULL
 	case *ast.LetStatement:
+		if env.IsConst(node.Name.Value) && env.IsRedeclared(node.Name.Value, node) {
+			return NewError("cannot reassign constant %s", node.Name.Value)
+		}
+		if env.Strict() && env.IsRedeclared(node.Name.Value, node) {
+			return NewError("%s already declared", node.Name.Value)
+		}
+
 		value := Eval(node.Value, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 
-		env.Set(node.Name.Value, value)
+		if node.IsConst() {
+			env.SetConst(node.Name.Value, value)
+		} else {
+			env.Set(node.Name.Value, value)
+		}
+		env.Declare(node.Name.Value, node)
+		return NULL
+	case *ast.MultiLetStatement:
+		for _, let := range node.Lets {
+			result := Eval(let, env)
+			if isAbrupt(result) {
+				return result
+			}
+		}
+		return NULL
+	case *ast.DestructureStatement:
+		return evalDestructure(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.Function:
 		return &object.Function{node.Parameters, node.Body, env}
+	case *ast.MacroLiteral:
+		return NewError("macro definitions must be top-level let statements")
 	case *ast.Call:
+		if isSpecialForm(node, "quote", env) {
+			if len(node.Arguments) != 1 {
+				return NewError("wrong number of arguments (expected = 1)")
+			}
+			return quote(node.Arguments[0], env)
+		}
+
+		if isSpecialForm(node, "recover", env) {
+			return evalRecover(node, env)
+		}
+
 		f := Eval(node.Function, env)
-		if isError(f) {
+		if isAbrupt(f) {
 			return f
 		}
 
 		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
 
-		return evalFunction(f, args)
+		result := evalFunction(f, args)
+
+		// An error from inside the called function's body already has a position
+		if err, ok := result.(*object.Error); ok && err.Line != 0 {
+			err.Trace = append(err.Trace, object.Frame{
+				Function: callName(node),
+				Line:     node.Line(),
+				Column:   node.Column(),
+			})
+		}
+		return result
 	case *ast.String:
 		return &object.String{node.Value}
 	case *ast.Array:
+		if object.MaxLiteralSize > 0 && len(node.Elements) > object.MaxLiteralSize {
+			return NewError("array literal too large: %d elements (max %d)",
+				len(node.Elements), object.MaxLiteralSize)
+		}
+
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
 
 		return &object.Array{Elements: elements}
 	case *ast.Index:
 		array := Eval(node.Array, env)
-		if isError(array) {
+		if isAbrupt(array) {
 			return array
 		}
 
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if isAbrupt(index) {
 			return index
 		}
 
@@ -104,7 +209,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalHash(node, env)
 	}
 
-	return nil
+	return NewError("cannot evaluate node type %T", node)
 }
 
 // Helper method for evaluating expressions
@@ -113,7 +218,7 @@ func evalExpressions(args []ast.Expression, env *object.Environment) []object.Ob
 
 	for _, a := range args {
 		value := Eval(a, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return []object.Object{value}
 		}
 
@@ -127,25 +232,142 @@ func evalExpressions(args []ast.Expression, env *object.Environment) []object.Ob
 func evalFunction(fobj object.Object, args []object.Object) object.Object {
 	switch f := fobj.(type) {
 	case *object.Function:
-		outerEnv := extendEnv(f, args)
-		value := Eval(f.Body, outerEnv)
-
-		result, ok := value.(*object.Return)
-		if ok {
-			return result.Value
-		} else {
-			return value
-		}
+		return callFunction(f, args, nil)
 	case *object.BuiltIn:
-		return f.Function(args...)
+		result := f.Call(args...)
+		if result == nil {
+			return NULL
+		}
+		if apply, ok := result.(*object.Apply); ok {
+			return evalApply(apply)
+		}
+		return result
 	default:
 		return NewError("not a function: %s", f.Type())
 	}
 }
 
+// Helper method for calling a user function
+// deferring is the function environment that deferred the call (nil unless called by evalDeferred)
+func callFunction(f *object.Function, args []object.Object, deferring *object.Environment) object.Object {
+	if len(args) != len(f.Parameters) {
+		return NewError("wrong number of arguments (expected = %d)", len(f.Parameters))
+	}
+
+	outerEnv := extendEnv(f, args)
+	outerEnv.SetDeferring(deferring)
+	value := Eval(f.Body, outerEnv)
+	value = evalDeferred(outerEnv, value)
+	return unwrapReturn(value)
+}
+
+// Helper method for carrying out a call requested by a builtin like apply
+func evalApply(apply *object.Apply) object.Object {
+	result := evalFunction(apply.Function, apply.Arguments)
+	if apply.Then != nil {
+		result = apply.Then(result)
+	}
+	return result
+}
+
+// Helper method for running deferred expressions after a function body
+// An error in a deferred expression replaces the result, unless the body already failed
+// A panic recovered by a deferred expression makes the function return null
+func evalDeferred(env *object.Environment, result object.Object) object.Object {
+	for _, d := range env.TakeDeferred() {
+		if p, ok := result.(*object.Panic); ok {
+			env.SetPanic(p)
+		}
+
+		value := evalDeferredExpression(d, env)
+
+		if _, ok := result.(*object.Panic); ok && env.Panic() == nil {
+			result = NULL
+		}
+		if isError(value) && !isError(result) {
+			result = value
+		}
+	}
+
+	env.SetPanic(nil)
+	return result
+}
+
+// Helper method for running one deferred expression of the function with environment env
+// A deferred call to a user function can recover env's panic, like one written inline
+func evalDeferredExpression(d object.Deferred, env *object.Environment) object.Object {
+	call, ok := d.Expression.(*ast.Call)
+	if !ok || isSpecialForm(call, "quote", d.Env) || isSpecialForm(call, "recover", d.Env) {
+		return Eval(d.Expression, d.Env)
+	}
+
+	f := Eval(call.Function, d.Env)
+	if isError(f) {
+		return f
+	}
+
+	args := evalExpressions(call.Arguments, d.Env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if fn, ok := f.(*object.Function); ok {
+		return callFunction(fn, args, env)
+	}
+	return evalFunction(f, args)
+}
+
+// Helper method for naming the function of a call in a stack trace
+func callName(node *ast.Call) string {
+	if _, ok := node.Function.(*ast.Function); ok {
+		return "fn"
+	}
+	return node.Function.String()
+}
+
+// Helper method for checking if call is to a special form like recover (and not a user-defined name)
+// Special forms get the unevaluated call, rather than evaluated arguments
+func isSpecialForm(node *ast.Call, name string, env *object.Environment) bool {
+	identifier, ok := node.Function.(*ast.Identifier)
+	if !ok || identifier.Value != name {
+		return false
+	}
+
+	_, defined := env.Get(identifier.Value)
+	return !defined
+}
+
+// Helper method for evaluating recover(), which stops the panic being unwound
+func evalRecover(node *ast.Call, env *object.Environment) object.Object {
+	if len(node.Arguments) != 0 {
+		return NewError("wrong number of arguments (expected = 0)")
+	}
+
+	value := env.Recover()
+	if value == nil {
+		return NULL
+	}
+	return value
+}
+
+// Helper method for getting the value out of a return
+// break/continue that reach a function boundary weren't inside a loop
+func unwrapReturn(obj object.Object) object.Object {
+	switch result := obj.(type) {
+	case *object.Return:
+		return result.Value
+	case *object.Break:
+		return NewError("break outside loop")
+	case *object.Continue:
+		return NewError("continue outside loop")
+	default:
+		return obj
+	}
+}
+
 // Helper method for extending environment for evaluating function
 func extendEnv(f *object.Function, args []object.Object) *object.Environment {
-	innerEnv := object.BuildInnerEnvironment(f.Env)
+	innerEnv := object.BuildFunctionEnvironment(f.Env)
 
 	// Bind arguments to parameter names
 	for i, p := range f.Parameters {
@@ -166,16 +388,18 @@ func evalBoolean(expression bool) object.Object {
 
 // Helper method for evaluating statements in a program
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
+	var result object.Object = NULL // Empty program
 
 	for _, statement := range program.Statements {
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
-		case *object.Return:
-			return result.Value
+		case *object.Return, *object.Break, *object.Continue:
+			return unwrapReturn(result)
 		case *object.Error:
 			return result
+		case *object.Panic:
+			return result
 		}
 	}
 	return result
@@ -183,13 +407,14 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 
 // Helper method for evaluating statements in a block statement
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
+	var result object.Object = NULL // Empty block
 
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
-		if result != nil &&
-			(result.Type() == object.RETURN_OBJECT || result.Type() == object.ERROR_OBJECT) {
+		if result != nil && (result.Type() == object.RETURN_OBJECT ||
+			result.Type() == object.ERROR_OBJECT || result.Type() == object.PANIC_OBJECT ||
+			result.Type() == object.BREAK_OBJECT || result.Type() == object.CONTINUE_OBJECT) {
 			return result
 		}
 	}
@@ -225,17 +450,37 @@ func evalBangPrefix(expression object.Object) object.Object {
 
 // Helper method for evaluating prefix -
 func evalMinusPrefix(expression object.Object) object.Object {
-	if expression.Type() != object.INTEGER_OBJECT {
+	switch expression := expression.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -expression.Value}
+	case *object.Float:
+		return &object.Float{Value: -expression.Value}
+	default:
 		return NewError("unknown operator: -%s", expression.Type())
 	}
-
-	result := expression.(*object.Integer).Value
-	return &object.Integer{Value: -result}
 }
 
 // Helper method for evaluating infix
 func evalInfix(left object.Object, operator string, right object.Object) object.Object {
 	switch {
+	case operator == "in":
+		return evalIn(left, right)
+	case operator == "not in":
+		result := evalIn(left, right)
+		if isError(result) {
+			return result
+		}
+		return evalBangPrefix(result)
+	case operator == "*" && left.Type() == object.STRING_OBJECT && right.Type() == object.INTEGER_OBJECT:
+		return evalStringRepeat(left.(*object.String).Value, right.(*object.Integer).Value)
+	case operator == "*" && left.Type() == object.INTEGER_OBJECT && right.Type() == object.STRING_OBJECT:
+		return evalStringRepeat(right.(*object.String).Value, left.(*object.Integer).Value)
+	case operator == "*" && left.Type() == object.ARRAY_OBJECT && right.Type() == object.INTEGER_OBJECT:
+		return evalArrayRepeat(left.(*object.Array).Elements, right.(*object.Integer).Value)
+	case operator == "*" && left.Type() == object.INTEGER_OBJECT && right.Type() == object.ARRAY_OBJECT:
+		return evalArrayRepeat(right.(*object.Array).Elements, left.(*object.Integer).Value)
+	case object.IsFloatOperation(left, right):
+		return evalFloatInfix(left, operator, right)
 	case left.Type() != right.Type():
 		return NewError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT:
@@ -255,14 +500,75 @@ func evalInfix(left object.Object, operator string, right object.Object) object.
 	}
 }
 
-// Helper method for evaluating string infix
+// Helper method for evaluating membership e.g. "1 in [1, 2]"
+func evalIn(item object.Object, container object.Object) object.Object {
+	found, ok := object.Contains(container, item)
+	if !ok {
+		return NewError("unsupported operands: %s in %s", item.Type(), container.Type())
+	}
+
+	return evalBoolean(found)
+}
+
+// Helper method for evaluating string infix (strings are ordered byte by byte)
 func evalStringInfix(left string, operator string, right string) object.Object {
-	if operator != "+" {
+	switch operator {
+	case "+":
+		return &object.String{left + right}
+	case "<":
+		return evalBoolean(left < right)
+	case ">":
+		return evalBoolean(left > right)
+	case "<=":
+		return evalBoolean(left <= right)
+	case ">=":
+		return evalBoolean(left >= right)
+	case "==":
+		return evalBoolean(left == right)
+	case "!=":
+		return evalBoolean(left != right)
+	default:
 		return NewError("unknown operator: %s %s %s",
 			object.STRING_OBJECT, operator, object.STRING_OBJECT)
 	}
+}
 
-	return &object.String{left + right}
+// Helper method for evaluating string * integer (e.g. "ab" * 3 is "ababab")
+func evalStringRepeat(str string, count int64) object.Object {
+	if count < 0 {
+		return NewError("negative repeat count: %d", count)
+	}
+	if object.RepeatTooLarge(len(str), count) {
+		return NewError("repeat count too large: %d", count)
+	}
+
+	return &object.String{strings.Repeat(str, int(count))}
+}
+
+// Helper method for evaluating array * integer (e.g. [0] * 3 is [0, 0, 0])
+func evalArrayRepeat(elements []object.Object, count int64) object.Object {
+	if count < 0 {
+		return NewError("negative repeat count: %d", count)
+	}
+
+	if len(elements) == 0 {
+		return &object.Array{Elements: []object.Object{}}
+	}
+	if object.RepeatTooLarge(len(elements), count) {
+		return NewError("repeat count too large: %d", count)
+	}
+
+	size := len(elements) * int(count)
+	if object.MaxLiteralSize > 0 && size > object.MaxLiteralSize {
+		return NewError("array too large: %d elements (max %d)", size, object.MaxLiteralSize)
+	}
+
+	repeated := make([]object.Object, 0, size)
+	for i := int64(0); i < count; i++ {
+		repeated = append(repeated, elements...)
+	}
+
+	return &object.Array{Elements: repeated}
 }
 
 // Helper method for evaluating integer infix
@@ -275,17 +581,60 @@ func evalIntegerInfix(left int64, operator string, right int64) object.Object {
 	case "*":
 		return &object.Integer{Value: left * right}
 	case "/":
+		if right == 0 {
+			return NewError("division by zero")
+		}
 		return &object.Integer{Value: left / right}
+	case "%":
+		if right == 0 {
+			return NewError("division by zero")
+		}
+		return &object.Integer{Value: left % right}
+	case "<":
+		return evalBoolean(left < right)
+	case ">":
+		return evalBoolean(left > right)
+	case "<=":
+		return evalBoolean(left <= right)
+	case ">=":
+		return evalBoolean(left >= right)
+	case "==":
+		return evalBoolean(left == right)
+	case "!=":
+		return evalBoolean(left != right)
+	default:
+		return NewError("unknown operator: %s %s %s", object.INTEGER_OBJECT, operator, object.INTEGER_OBJECT)
+	}
+}
+
+// Helper method for evaluating float infix (integer operands are promoted)
+func evalFloatInfix(leftObj object.Object, operator string, rightObj object.Object) object.Object {
+	left, right := object.ToFloat(leftObj), object.ToFloat(rightObj)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: left + right}
+	case "-":
+		return &object.Float{Value: left - right}
+	case "*":
+		return &object.Float{Value: left * right}
+	case "/":
+		// IEEE semantics, unlike integers: x / 0.0 is +Inf, -Inf or NaN (NaN != NaN)
+		return &object.Float{Value: left / right}
 	case "<":
 		return evalBoolean(left < right)
 	case ">":
 		return evalBoolean(left > right)
+	case "<=":
+		return evalBoolean(left <= right)
+	case ">=":
+		return evalBoolean(left >= right)
 	case "==":
 		return evalBoolean(left == right)
 	case "!=":
 		return evalBoolean(left != right)
 	default:
-		return NewError("unknown operator: %s %s %s", left, operator, right)
+		return NewError("unknown operator: %s %s %s", leftObj.Type(), operator, rightObj.Type())
 	}
 }
 
@@ -293,19 +642,80 @@ func evalIntegerInfix(left int64, operator string, right int64) object.Object {
 func evalIf(i *ast.If, env *object.Environment) object.Object {
 	condition := Eval(i.Condition, env)
 
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
 	if isTrue(condition) {
-		return Eval(i.Consequence, env)
+		return Eval(i.Consequence, blockEnvironment(env))
 	} else if i.Alternative != nil {
-		return Eval(i.Alternative, env)
+		return Eval(i.Alternative, blockEnvironment(env))
 	} else {
 		return NULL
 	}
 }
 
+// Evaluate body in an inner environment holding the with bindings
+func evalWith(w *ast.With, env *object.Environment) object.Object {
+	inner := object.BuildInnerEnvironment(env)
+
+	for i, name := range w.Names {
+		value := Eval(w.Values[i], inner)
+		if isAbrupt(value) {
+			return value
+		}
+		inner.Set(name.Value, value)
+	}
+
+	return Eval(w.Body, inner)
+}
+
+// Helper method for evaluating try, which stops an error in its body from propagating (panics aren't caught)
+// Both bodies run in their own inner environment, so their lets don't outlive them
+// The catch one has the error message (a string, not the error) bound
+func evalTry(t *ast.Try, env *object.Environment) object.Object {
+	result := Eval(t.Body, object.BuildInnerEnvironment(env))
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		return result
+	}
+
+	inner := object.BuildInnerEnvironment(env)
+	inner.Set(t.Name.Value, &object.String{Value: err.Message})
+	return Eval(t.Handler, inner)
+}
+
+// Helper method for evaluating while loops (always null, unless the body returns or fails)
+func evalWhile(w *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(w.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+
+		if !isTrue(condition) {
+			return NULL
+		}
+
+		result := Eval(w.Body, blockEnvironment(env))
+		if result != nil && result.Type() == object.BREAK_OBJECT {
+			return NULL
+		}
+		if result != nil && (result.Type() == object.RETURN_OBJECT || isError(result)) {
+			return result
+		}
+	}
+}
+
+// Helper method to get the environment an if or while body runs in (a fresh one with block scoping)
+func blockEnvironment(env *object.Environment) *object.Environment {
+	if env.BlockScoped() {
+		return object.BuildInnerEnvironment(env)
+	}
+	return env
+}
+
 // Helper method for defining what is true
 func isTrue(obj object.Object) bool {
 	switch obj {
@@ -320,6 +730,54 @@ func isTrue(obj object.Object) bool {
 	}
 }
 
+// Helper method for evaluating destructuring let statements
+// Defaults are only evaluated for names past the end of the array
+func evalDestructure(node *ast.DestructureStatement, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if isAbrupt(value) {
+		return value
+	}
+
+	array, ok := value.(*object.Array)
+	if !ok {
+		return NewError("cannot destructure %s", value.Type())
+	}
+
+	for _, name := range node.Names {
+		if env.IsConst(name.Value) {
+			return NewError("cannot reassign constant %s", name.Value)
+		}
+		if env.Strict() && env.IsRedeclared(name.Value, node) {
+			return NewError("%s already declared", name.Value)
+		}
+	}
+
+	for i, name := range node.Names {
+		env.Declare(name.Value, node)
+
+		if i < len(array.Elements) {
+			env.Set(name.Value, array.Elements[i])
+			continue
+		}
+
+		if node.Defaults[i] == nil {
+			elements := "elements"
+			if len(array.Elements) == 1 {
+				elements = "element"
+			}
+			return NewError("missing value for %s (array has %d %s)", name.Value, len(array.Elements), elements)
+		}
+
+		element := Eval(node.Defaults[i], env)
+		if isAbrupt(element) {
+			return element
+		}
+		env.Set(name.Value, element)
+	}
+
+	return NULL
+}
+
 // Helper method for evaluating identifiers
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	value, ok := env.Get(node.Value)
@@ -340,30 +798,36 @@ func NewError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-// Helper method for stopping errors from bubbling up
+// Helper method for stopping errors (and panics) from bubbling up
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJECT
+		return obj.Type() == object.ERROR_OBJECT || obj.Type() == object.PANIC_OBJECT
 	}
 	return false
 }
 
+// Helper method for stopping evaluation of an expression part way through
+// True for errors and for a return, break or continue inside it (e.g. "let x = if (done) { break; }")
+func isAbrupt(obj object.Object) bool {
+	if obj != nil {
+		switch obj.Type() {
+		case object.RETURN_OBJECT, object.BREAK_OBJECT, object.CONTINUE_OBJECT:
+			return true
+		}
+	}
+	return isError(obj)
+}
+
 // Helper method for evaluating index expressions
 func evalIndex(accessObj object.Object, indexObj object.Object) object.Object {
 	switch {
 	case accessObj.Type() == object.ARRAY_OBJECT && indexObj.Type() == object.INTEGER_OBJECT:
-		array := accessObj.(*object.Array)
-		index := indexObj.(*object.Integer).Value
-
-		end := int64(len(array.Elements) - 1)
-		if index < 0 || index > end {
-			return NULL
-		} else {
-			return array.Elements[index]
-		}
+		return accessObj.(*object.Array).Index(indexObj.(*object.Integer).Value)
+	case accessObj.Type() == object.STRING_OBJECT && indexObj.Type() == object.INTEGER_OBJECT:
+		return accessObj.(*object.String).Index(indexObj.(*object.Integer).Value)
 	case accessObj.Type() == object.HASH_OBJECT:
 		hash := accessObj.(*object.Hash)
-		key, ok := indexObj.(object.Hashable)
+		key, ok := object.AsHashKey(indexObj)
 		if !ok {
 			return NewError("unusable as hash key")
 		}
@@ -381,24 +845,29 @@ func evalIndex(accessObj object.Object, indexObj object.Object) object.Object {
 
 // Helper method for evaluating hash expressions
 func evalHash(node *ast.Hash, env *object.Environment) object.Object {
+	if object.MaxLiteralSize > 0 && len(node.Pairs) > object.MaxLiteralSize {
+		return NewError("hash literal too large: %d pairs (max %d)",
+			len(node.Pairs), object.MaxLiteralSize)
+	}
+
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for k, v := range node.Pairs {
 		// Get key
 		key := Eval(k, env)
-		if isError(key) {
+		if isAbrupt(key) {
 			return key
 		}
 
 		// Get hashed key
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashKey(key)
 		if !ok {
-			return NewError("ununsable as hash key")
+			return NewError("unusable as hash key")
 		}
 
 		// Get value
 		value := Eval(v, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 
@@ -1,10 +1,13 @@
 package evaluator
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
+	"go_interpreter/ast"
 	"go_interpreter/lexer"
 	"go_interpreter/object"
 	"go_interpreter/parser"
+	"os"
 	"testing"
 )
 
@@ -23,6 +26,10 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"-4*6", -24},
 		{"6/7", 0},
 		{"10/5 + 2", 4},
+		{"10 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	for _, test := range tests {
@@ -31,6 +38,71 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14", "3.14"},
+		{"-1.5", "-1.5"},
+		{"1.5 + 2", "3.5"},
+		{"2 + 3.5", "5.5"},
+		{"0.5 * 4", "2.0"},
+		{"7 / 2.0", "3.5"},
+		{"7 / 2", "3"},
+		{"1.0 - 0.25", "0.75"},
+		{"1.5 < 2", "true"},
+		{"2 > 1.5", "true"},
+		{"2 == 2.0", "true"},
+		{"2.5 != 2.5", "false"},
+		{"let half = fn(x) { x / 2.0 }; half(5)", "2.5"},
+		{"1.5 % 1", "ERROR: unknown operator: FLOAT % INTEGER"},
+		{"1 % 1.5", "ERROR: unknown operator: INTEGER % FLOAT"},
+		{`1.5 + "a"`, "ERROR: type mismatch: FLOAT + STRING"},
+		{`{1.5: "a"}[1.5]`, "a"},
+		// Hashing and membership agree with ==
+		{`{0.0: "a"}[-0.0]`, "a"},
+		{"1 in [1.0]", "true"},
+		{"2.0 in [1, 2]", "true"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestNaNInf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.0 / 0.0", "+Inf"},
+		{"-1.0 / 0", "-Inf"},
+		{"1 / 0.0", "+Inf"},
+		{"0.0 / 0.0", "NaN"},
+		{"1.0 / 0.0 > 1000000", "true"},
+		{"let nan = 0.0 / 0.0; nan == nan", "false"},
+		{"let nan = 0.0 / 0.0; nan != nan", "true"},
+		{"let nan = 0.0 / 0.0; nan < 1", "false"},
+		{"let nan = 0.0 / 0.0; nan >= 1", "false"},
+		{"let inf = 1.0 / 0.0; inf == inf", "true"},
+		{"let inf = 1.0 / 0.0; inf - inf", "NaN"},
+		{"1 / 0", "ERROR: division by zero"},
+		// NaN isn't equal to itself, so it can't be found or used as a hash key
+		{"let nan = 0.0 / 0.0; nan in [nan]", "false"},
+		{"{0.0 / 0.0: 1}", "ERROR: unusable as hash key"},
+		{"{1: 2}[0.0 / 0.0]", "ERROR: unusable as hash key"},
+		{"(0.0 / 0.0) in {1: 2}", "ERROR: unsupported operands: FLOAT in HASH"},
+		{"delete({1: 2}, 0.0 / 0.0)", "ERROR: unusable as hash key: FLOAT"},
+		{"delete({0.0: 1, 2: 3}, -0.0)", "{2: 3}"},
+		{"-0.0 in {0.0: 1}", "true"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
 // Testing boolean expressions e.g. "true;"
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
@@ -47,6 +119,27 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"true != false", true},
 		{"(1 < 2) == true", true},
 		{"(1 > 2) == false", true},
+		{"5 <= 5", true},
+		{"5 >= 5", true},
+		{"4 <= 5", true},
+		{"4 >= 5", false},
+		{"1 + 1 <= 2 == true", true},
+		{"1.5 <= 1.5", true},
+		{"2 >= 2.5", false},
+		{`"a" < "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"abc" >= "abc"`, true},
+		{`"abc" > "ab"`, true},
+		{`"abc" == "abc"`, true},
+		{`"abc" != "abc"`, false},
+		{`"abc" == "abd"`, false},
+		{`"abc" != "ab"`, true},
+		{`let s = "ab"; s + "c" == "abc"`, true},
+		{`"" == ""`, true},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"let f = fn(x) { x }; let g = f; g != f", false},
+		{"len == len", true},
 	}
 
 	for _, test := range tests {
@@ -152,6 +245,21 @@ func TestErrorHandling(t *testing.T) {
 		{
 			"foobar", "identifier not found: foobar",
 		},
+		{
+			"10 % 0", "division by zero",
+		},
+		{
+			"5 / 0", "division by zero",
+		},
+		{
+			"let zero = 1 - 1; let f = fn(x) { x / zero }; f(5); 1", "division by zero",
+		},
+		{
+			`{"a": 1}[fn(x) { x }]`, "unusable as hash key",
+		},
+		{
+			"{[1]: 2}", "unusable as hash key",
+		},
 	}
 
 	for _, test := range tests {
@@ -180,6 +288,157 @@ func TestLetStatement(t *testing.T) {
 	for _, test := range tests {
 		testInteger(t, testEval(test.input), test.expected)
 	}
+
+	// Let statements evaluate to null
+	testNull(t, testEval("let x = 5"))
+	testNull(t, testEval("let f = fn() { let y = 1; }; f();"))
+}
+
+func TestMultiLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1, b = 2, c = 3; [a, b, c]", "[1, 2, 3]"},
+		{"let a = 2, b = a * 10; b", "20"},
+		{"let a = 1; let a = 5, b = a + 1; b", "6"},
+		{"let f = fn() { let x = 1, y = x + 1; y }; f()", "2"},
+		{"let a = 1, b = 2", "null"},
+		{"const a = 1, b = 2; let b = 3", "ERROR: cannot reassign constant b"},
+		{"let a = 1, b = a + true, c = 3; c", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestDestructure(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// Full array: defaults unused
+		{"let [a, b = 0] = [1, 2]; a;", 1},
+		{"let [a, b = 0] = [1, 2]; b;", 2},
+		{"let [a, b = 0, c = 3] = [1, 2, 5]; a + b + c;", 8},
+		// Short array: defaults applied
+		{"let [a, b = 0] = [1]; b;", 0},
+		{"let [a, b = a * 10] = [4]; b;", 40},
+		{"let [a = 7, b = 8] = []; a + b;", 15},
+	}
+
+	for _, test := range tests {
+		testInteger(t, testEval(test.input), test.expected)
+	}
+
+	testNull(t, testEval("let [a, b] = [1, 2]"))
+
+	// Defaults are not evaluated when the element is present
+	testInteger(t, testEval("let [a = undefined] = [1]; a;"), 1)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1]; b;", "missing value for b (array has 1 element)"},
+		{"let [a, b, c] = [1, 2]; c;", "missing value for c (array has 2 elements)"},
+		{"let [a] = []; a;", "missing value for a (array has 0 elements)"},
+		{"let [a] = 5; a;", "cannot destructure INTEGER"},
+	}
+
+	for _, test := range errors {
+		errObj, ok := testEval(test.input).(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned for %s", test.input)
+		}
+		assert.Equal(t, test.expected, errObj.Message, test.input)
+	}
+}
+
+func TestConst(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 5; x * 2", "10"},
+		{"let x = 5; let x = 6; x", "6"},
+		{"const x = 5; let x = 6; x", "ERROR: cannot reassign constant x"},
+		{"const x = 5; const x = 6; x", "ERROR: cannot reassign constant x"},
+		{"const x = 5; let [x] = [6]; x", "ERROR: cannot reassign constant x"},
+		{"let x = 5; const x = 6; x", "6"},
+		{"const x = 5; let f = fn() { let x = 6; x }; f() + x", "11"},
+		{"const x = 5; let f = fn(x) { x }; f(7)", "7"},
+		// Running the same const in a loop body again isn't a reassignment
+		{"let i = 0; while (i < 2) { const y = 1; let i = i + 1; } i", "2"},
+		{"let i = 0; while (i < 2) { const y = i; let i = i + 1; } y", "1"},
+		{"let i = 0; while (i < 2) { const y = 1; let y = 2; let i = i + 1; } i", "ERROR: cannot reassign constant y"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		input  string
+		strict string
+		loose  string
+	}{
+		{"let x = 5; let x = 6; x", "ERROR: x already declared", "6"},
+		{"let x = 5; let [y, x] = [1, 2]; x", "ERROR: x already declared", "2"},
+		// Shadowing in an inner scope is always allowed
+		{"let x = 5; let f = fn() { let x = 6; x }; f() + x", "11", "11"},
+		{"let x = 5; let f = fn(y) { let x = y; x }; f(7)", "7", "7"},
+		{"let f = fn() { let x = 1; let x = 2; x }; f()", "ERROR: x already declared", "2"},
+		// A let in a loop body is one declaration, however many times it runs
+		{"let j = 0; let f = fn() { while (j < 2) { let y = j; let [z] = [y]; let j = j + 1; } z }; f()", "1", "1"},
+		// but it can't update a name declared before the loop
+		{"let f = fn() { let j = 0; while (j < 2) { let j = j + 1; } j }; f()", "ERROR: j already declared", "2"},
+	}
+
+	for _, test := range tests {
+		l := lexer.BuildLexer(test.input)
+		prog := parser.BuildParser(l).ParseProgram()
+
+		env := object.BuildEnvironment()
+		env.SetStrict(true)
+		assert.Equal(t, test.strict, Eval(prog, env).Inspect(), test.input)
+
+		assert.Equal(t, test.loose, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestBlockScope(t *testing.T) {
+	tests := []struct {
+		input  string
+		scoped string
+		loose  string
+	}{
+		{"if (true) { let x = 1; }; x", "ERROR: identifier not found: x", "1"},
+		{"if (false) { 1 } else { let y = 2; }; y", "ERROR: identifier not found: y", "2"},
+		{"while (true) { let z = 3; break; } z", "ERROR: identifier not found: z", "3"},
+		// Outer bindings are visible inside the block, and shadowed only there
+		{"let x = 1; if (true) { let x = x + 10; x }", "11", "11"},
+		{"let x = 1; if (true) { let x = x + 10; }; x", "1", "11"},
+		{"let f = fn() { if (true) { let x = 5; }; x }; f()", "ERROR: identifier not found: x", "5"},
+	}
+
+	for _, test := range tests {
+		l := lexer.BuildLexer(test.input)
+		prog := parser.BuildParser(l).ParseProgram()
+
+		env := object.BuildEnvironment()
+		env.SetBlockScoped(true)
+		assert.Equal(t, test.scoped, Eval(prog, env).Inspect(), test.input)
+
+		assert.Equal(t, test.loose, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestUnicodeIdentifier(t *testing.T) {
+	testInteger(t, testEval("let π = 3; let größe = π * 2; größe + π"), 9)
 }
 
 func TestFunctionDefinition(t *testing.T) {
@@ -210,6 +469,151 @@ func TestFunctionCall(t *testing.T) {
 			"let add = fn(x, y) { x + y;}; add(5+2, add(2, 3));",
 			12,
 		},
+		{
+			"let g = fn() { return 5; }; let f = fn(x) { x * 2 }; f(g());",
+			10,
+		},
+		// A return inside an argument or element returns from the enclosing function, skipping the call
+		{
+			"let f = fn(x) { x + 1 }; f(if (true) { return 2; });",
+			2,
+		},
+		{
+			"[if (true) { return 4; }][0] + 1",
+			4,
+		},
+		{
+			"let f = fn(x) { x + 1 }; let g = fn() { f(if (true) { return 2; }) + 10 }; g() + 100",
+			102,
+		},
+	}
+
+	for _, test := range tests {
+		testInteger(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestArrowFunction(t *testing.T) {
+	tests := []struct {
+		arrow string
+		block string
+	}{
+		{"let f = fn(x) => x * 2; f(4);", "let f = fn(x) { return x * 2 }; f(4);"},
+		{"(fn(x, y) => x + y)(1, 2)", "fn(x, y) { return x + y }(1, 2)"},
+		{"let add = fn(x) => fn(y) => x + y; add(2)(3);", "let add = fn(x) { fn(y) { x + y } }; add(2)(3);"},
+	}
+
+	for _, test := range tests {
+		arrow := testEval(test.arrow)
+		block := testEval(test.block)
+		assert.Equal(t, block.Inspect(), arrow.Inspect(), test.arrow)
+	}
+}
+
+func TestEmptyFunctionBody(t *testing.T) {
+	testNull(t, testEval("fn(){}()"))
+	testNull(t, testEval("let f = fn(x) {}; f(1);"))
+}
+
+func TestNonNilResult(t *testing.T) {
+	testNull(t, testEval(""))
+	testNull(t, testEval("if (true) {}"))
+	testNull(t, testEval("first([])"))
+
+	env := object.BuildEnvironment()
+	result := Eval(&ast.BadStatement{}, env)
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", result, result)
+	}
+	assert.Equal(t, "cannot evaluate node type *ast.BadStatement", errObj.Message)
+
+	result = Eval(&ast.ExpressionStatement{}, env)
+	_, ok = result.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", result, result)
+	}
+}
+
+func TestMaxLiteralSize(t *testing.T) {
+	object.MaxLiteralSize = 2
+	defer func() { object.MaxLiteralSize = 0 }()
+
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"[1, 2, 3]", "array literal too large: 3 elements (max 2)"},
+		{`{"a": 1, "b": 2, "c": 3}`, "hash literal too large: 3 pairs (max 2)"},
+	}
+
+	for _, test := range tests {
+		result := testEval(test.input)
+
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned. got=%T(%+v)", result, result)
+		}
+
+		assert.Equal(t, test.expectedMessage, errObj.Message, test.input)
+	}
+
+	testInteger(t, testEval("[1, 2][1]"), 2)
+}
+
+func TestArrayIndex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3][0]", "1"},
+		{"[1, 2, 3][2]", "3"},
+		{"[1, 2, 3][3]", "null"},
+		// Negative indices count back from the end
+		{"[1, 2, 3][-1]", "3"},
+		{"[1, 2, 3][-2]", "2"},
+		{"let a = [1, 2, 3]; a[-len(a)]", "1"},
+		{"let a = [1, 2, 3]; a[-(len(a) + 1)]", "null"},
+		{"[][-1]", "null"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestStringIndex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello"[0]`, "h"},
+		{`let s = "hello"; s[len(s) - 1]`, "o"},
+		{`"héllo"[1]`, "é"},
+		{`"hello"[5]`, "null"},
+		{`"hello"[-1]`, "null"},
+		{`""[0]`, "null"},
+		{`"hello"["a"]`, "ERROR: index operator not supported: STRING"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			"let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(4);",
+			10,
+		},
+		{
+			"let g = fn() { let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(4); }; g();",
+			10,
+		},
 	}
 
 	for _, test := range tests {
@@ -217,6 +621,290 @@ func TestFunctionCall(t *testing.T) {
 	}
 }
 
+func TestLineProfile(t *testing.T) {
+	input := `let f = fn(n) {
+		if (n == 0) { 0 } else { n + f(n - 1) }
+	};
+	f(50);`
+
+	PROFILE_EVAL = true
+	ResetProfile()
+	defer func() { PROFILE_EVAL = false }()
+
+	testInteger(t, testEval(input), 1275)
+
+	for line, count := range LineProfile {
+		if line != 2 {
+			assert.True(t, LineProfile[2] > count, "Line 2 should dominate")
+		}
+	}
+	assert.True(t, LineProfile[4] > 0, "Line 4 should be counted")
+}
+
+func TestDefer(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+		expected       interface{}
+	}{
+		{
+			"let f = fn() { defer print(1); defer print(2); print(0); 5 }; f();",
+			"0\n2\n1\n",
+			5,
+		},
+		{
+			`let f = fn(x) { defer print("done"); if (x) { return 1; } print("not reached"); 2 }; f(true);`,
+			"done\n",
+			1,
+		},
+		{
+			`let f = fn() { defer print("cleanup"); 1 + true; }; f();`,
+			"cleanup\n",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`let f = fn() { let x = 1; defer print(x); let x = 2; x }; f();`,
+			"2\n",
+			2,
+		},
+		{
+			"defer print(1);",
+			"",
+			"defer outside function",
+		},
+	}
+
+	defer func() { object.Output = os.Stdout }()
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		object.Output = &out
+
+		result := testEval(test.input)
+		assert.Equal(t, test.expectedOutput, out.String(), test.input)
+
+		switch expected := test.expected.(type) {
+		case int:
+			testInteger(t, result, int64(expected))
+		case string:
+			errObj, ok := result.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned. got=%T(%+v)", result, result)
+			}
+			assert.Equal(t, expected, errObj.Message)
+		}
+	}
+}
+
+func TestPanicRecover(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+		expected       string
+	}{
+		{
+			`let deep = fn(n) { if (n == 0) { panic("bottom"); } deep(n - 1) + 1 };
+			let top = fn() { defer print(recover()); deep(50) };
+			top();`,
+			"bottom\n",
+			"null",
+		},
+		{
+			`let f = fn() { defer print("unwinding"); panic(1); print("not reached"); };
+			f();`,
+			"unwinding\n",
+			"panic: 1",
+		},
+		{
+			`let f = fn() { defer fn() { print(recover()); }(); panic([1, 2]); };
+			f(); 5`,
+			"[1, 2]\n",
+			"5",
+		},
+		{
+			"let f = fn() { recover() }; f();",
+			"",
+			"null",
+		},
+		// A deferred named function can recover too
+		{
+			"let r = fn() { print(recover()) }; let f = fn() { defer r(); panic(1) }; f(); 2",
+			"1\n",
+			"2",
+		},
+		// but not a function it calls
+		{
+			"let g = fn() { recover() }; let r = fn() { g() }; let f = fn() { defer r(); panic(1) }; f(); 2",
+			"",
+			"panic: 1",
+		},
+	}
+
+	defer func() { object.Output = os.Stdout }()
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		object.Output = &out
+
+		result := testEval(test.input)
+		assert.Equal(t, test.expectedOutput, out.String(), test.input)
+		assert.Equal(t, test.expected, result.Inspect(), test.input)
+	}
+}
+
+func TestWhile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; let sum = 0; while (i < 5) { let i = i + 1; let sum = sum + i; } sum", "15"},
+		{"let i = 0; while (i < 3) { let i = i + 1; } i", "3"},
+		{"while (false) { }", "null"},
+		{"let i = 10; while (i < 3) { let i = i + 1; } i", "10"},
+		{"let f = fn() { let i = 0; while (true) { if (i == 4) { return i * 10; } let i = i + 1; } }; f()", "40"},
+		{"let i = 0; while (true) { let i = i + 1; i + true; }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"while (undefined) { }", "ERROR: identifier not found: undefined"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestWith(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"with (x = 1, y = 2) { x + y }", "3"},
+		{"with (x = 2, y = x * 10) { y }", "20"},
+		{"with () { 5 }", "5"},
+		{"let x = 1; with (x = 10) { x } + x", "11"},
+		{"with (x = 1) { let y = x + 1; y }", "2"},
+		{"let f = fn() { with (x = 3) { return x * 2; }; 0 }; f()", "6"},
+		// Bindings (and lets inside the block) are gone after the block
+		{"with (x = 1) { x }; x", "ERROR: identifier not found: x"},
+		{"with (x = 1) { let y = 2; }; y", "ERROR: identifier not found: y"},
+		{"with (x = 1 + true) { x }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestTry(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 + 2 } catch (e) { 0 }", "3"},
+		{`try { int("abc") } catch (e) { -1 }`, "-1"},
+		// The caught value is the error message
+		{"try { 1 + true } catch (e) { e }", "type mismatch: INTEGER + BOOLEAN"},
+		{"try { 1 + true } catch (e) { type(e) }", "STRING"},
+		{"let f = fn(x) { x / 0 }; try { f(1) } catch (e) { e }", "division by zero"},
+		// Evaluation continues after a caught error
+		{"try { foo } catch (e) { 1 }; 2", "2"},
+		{"let x = try { 1 / 0 } catch (e) { 5 }; x * 2", "10"},
+		// Nested: only the innermost try catches
+		{"try { try { foo } catch (e) { bar } } catch (e) { e }", "identifier not found: bar"},
+		// Returns pass through
+		{"let f = fn() { try { return 1; } catch (e) { 2 }; 3 }; f()", "1"},
+		// The catch variable is only bound in the catch body
+		{"try { foo } catch (e) { e }; e", "ERROR: identifier not found: e"},
+		// Errors in the catch body propagate
+		{"try { foo } catch (e) { e + 1 }", "ERROR: type mismatch: STRING + INTEGER"},
+		// Lets in either body stay in it, like the catch variable
+		{"try { let x = 1; x } catch (e) { 0 }; x", "ERROR: identifier not found: x"},
+		{"try { foo } catch (e) { let y = 2; y }; y", "ERROR: identifier not found: y"},
+		{"let x = 1; try { let x = 2; } catch (e) { 0 }; x", "1"},
+		{"let x = 1; try { foo } catch (e) { let x = 2; }; x", "1"},
+		// Panics aren't errors: they pass through to a deferred recover()
+		{"try { panic(1) } catch (e) { 2 }", "panic: 1"},
+		{"let f = fn() { defer recover(); try { panic(1) } catch (e) { 2 }; 3 }; f()", "null"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } } i", "3"},
+		{"let i = 0; let sum = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue; } let sum = sum + i; } sum", "13"},
+		{"while (true) { break; 1 + true; }", "null"},
+		// break only exits the innermost loop
+		{`let i = 0; let count = 0;
+		  while (i < 3) {
+		    let i = i + 1;
+		    let j = 0;
+		    while (true) { let j = j + 1; if (j > i) { break; } let count = count + 1; }
+		  }
+		  count`, "6"},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i > 2) { break; } } i }; f()", "3"},
+		{"break;", "ERROR: break outside loop"},
+		{"if (true) { continue; } 1", "ERROR: continue outside loop"},
+		{"let f = fn() { break; }; f()", "ERROR: break outside loop"},
+		{"while (true) { let f = fn() { break; }; f(); }", "ERROR: break outside loop"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       string
+		expectedOutput string
+	}{
+		{`false && print("right")`, "false", ""},
+		{`true || print("right")`, "true", ""},
+		{`let f = fn() { print("left"); false }; f() && print("right")`, "false", "left\n"},
+		{`true && print("right")`, "null", "right\n"},
+		{`false || print("right")`, "null", "right\n"},
+		// Operands are returned, not converted to booleans
+		{`1 && "b"`, "b", ""},
+		{`0 || "b"`, "0", ""},
+		{`if (false) { 1 } || 2`, "2", ""},
+		{"false && undefined", "false", ""},
+		{"true && undefined", "ERROR: identifier not found: undefined", ""},
+		{"1 > 2 || 3 > 2 && 2 > 1", "true", ""},
+	}
+
+	defer func() { object.Output = os.Stdout }()
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		object.Output = &out
+
+		result := testEval(test.input)
+		assert.Equal(t, test.expectedOutput, out.String(), test.input)
+		assert.Equal(t, test.expected, result.Inspect(), test.input)
+	}
+}
+
+func TestMetaNotVisible(t *testing.T) {
+	env := object.BuildEnvironment()
+	env.SetMeta("secret", &object.Integer{Value: 42})
+
+	l := lexer.BuildLexer("secret")
+	p := parser.BuildParser(l)
+	result := Eval(p.ParseProgram(), env)
+
+	assert.Equal(t, "ERROR: identifier not found: secret", result.Inspect())
+
+	meta, ok := env.GetMeta("secret")
+	assert.True(t, ok)
+	testInteger(t, meta, 42)
+}
+
 func TestClosure(t *testing.T) {
 	input := "let a = fn(x) { fn(y) {x+y}}; let b = a(2); b(3);"
 	testInteger(t, testEval(input), 5)
@@ -244,6 +932,481 @@ func TestStringConcatenation(t *testing.T) {
 	assert.Equal(t, str.Value, "foo bar", "Expected value of concatenated string")
 }
 
+func TestStringRepeat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"ab" * 3`, "ababab"},
+		{`3 * "ab"`, "ababab"},
+		{`"ab" * 0`, ""},
+		{`0 * "ab"`, ""},
+		{`let s = "-"; s * (1 + 1)`, "--"},
+		{`"" * 9223372036854775807`, ""},
+	}
+
+	for _, test := range tests {
+		result := testEval(test.input)
+		str, ok := result.(*object.String)
+		if !ok {
+			t.Fatalf("Object isn't string. got=%T(%+v)", result, result)
+		}
+		assert.Equal(t, test.expected, str.Value, test.input)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`"ab" * -1`, "negative repeat count: -1"},
+		{`-2 * "ab"`, "negative repeat count: -2"},
+		{`"ab" * 4611686018427387904`, "repeat count too large: 4611686018427387904"},
+		{`"a" * 1000000000`, "repeat count too large: 1000000000"},
+		{`"ab" - 1`, "type mismatch: STRING - INTEGER"},
+	}
+
+	for _, test := range errors {
+		errObj, ok := testEval(test.input).(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned for %s", test.input)
+		}
+		assert.Equal(t, test.expected, errObj.Message, test.input)
+	}
+}
+
+func TestArrayRepeat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[0] * 3", "[0, 0, 0]"},
+		{"2 * [1, 2]", "[1, 2, 1, 2]"},
+		{"[1, 2] * 0", "[]"},
+		{"[] * 5", "[]"},
+		{"let a = [1]; let b = a * 2; a", "[1]"},
+		{"[0] * -1", "ERROR: negative repeat count: -1"},
+		{"[] * 9223372036854775807", "[]"},
+		{"[1] * 9223372036854775807", "ERROR: repeat count too large: 9223372036854775807"},
+		{"[1, 2] * 4611686018427387904", "ERROR: repeat count too large: 4611686018427387904"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+
+	object.MaxLiteralSize = 4
+	defer func() { object.MaxLiteralSize = 0 }()
+	assert.Equal(t, "[1, 2, 1, 2]", testEval("[1, 2] * 2").Inspect())
+	assert.Equal(t, "ERROR: array too large: 6 elements (max 4)", testEval("[1, 2] * 3").Inspect())
+}
+
+func TestIn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 in [1, 2, 3]", "true"},
+		{"4 in [1, 2, 3]", "false"},
+		{`"b" in ["a", "b"]`, "true"},
+		{"[1] in [[1], [2]]", "true"},
+		{`"a" in {"a": 1}`, "true"},
+		{`"b" in {"a": 1}`, "false"},
+		{"1 in {1: 2}", "true"},
+		{`"ell" in "hello"`, "true"},
+		{`"xyz" in "hello"`, "false"},
+		{"1 + 1 in [2]", "true"},
+		{"let f = fn() { 1 }; f in [1, f]", "true"},
+		{"fn() { 1 } in [fn() { 1 }]", "false"},
+		{"len in [len]", "true"},
+		{"1 in 2", "ERROR: unsupported operands: INTEGER in INTEGER"},
+		{"[1] in {}", "ERROR: unsupported operands: ARRAY in HASH"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestNotIn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 not in [1, 2, 3]", "false"},
+		{"4 not in [1, 2, 3]", "true"},
+		{`"a" not in {"a": 1}`, "false"},
+		{`"b" not in {"a": 1}`, "true"},
+		{`"ell" not in "hello"`, "false"},
+		{`"xyz" not in "hello"`, "true"},
+		{"1 not in 2", "ERROR: unsupported operands: INTEGER in INTEGER"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double |> inc", "11"},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> inc |> double", "12"},
+		{`"four" |> len`, "4"},
+		{"1 + 2 |> fn(x) => x * 10", "30"},
+		{"1 |> 2", "ERROR: not a function: INTEGER"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`values({"b": 2, "a": 1, "c": [3]})`, "[1, 2, [3]]"},
+		{`values({2: "two", 1: "one"})`, "[one, two]"},
+		{`values({10: "ten", 9: "nine", 100: "hundred", -5: "minus five"})`, "[minus five, nine, ten, hundred]"},
+		{`values({"a": "string", true: "true", 2.5: "float", false: "false", 1: "int", 3: "three"})`, "[int, float, three, false, true, string]"},
+		{`values({1: "int", 1.0: "float", 0.5: "half"})`, "[half, int, float]"},
+		{`values({})`, "[]"},
+		{`let h = {"x": 1}; let v = values(h); len(v) + len(h)`, "2"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"type(5)", "INTEGER"},
+		{"type(2.5)", "FLOAT"},
+		{`type("a")`, "STRING"},
+		{"type([1])", "ARRAY"},
+		{"type({})", "HASH"},
+		{"type(true)", "BOOLEAN"},
+		{"type(fn(x) { x })", "FUNCTION"},
+		{"type(len)", "BUILTIN"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`int("42")`, "42"},
+		{`int("-7")`, "-7"},
+		{`int("42") + 1`, "43"},
+		{"int(3.9)", "3"},
+		{"int(-3.9)", "-3"},
+		{"int(5)", "5"},
+		{`int("abc")`, `ERROR: could not parse "abc" as integer`},
+		{`int("4.2")`, `ERROR: could not parse "4.2" as integer`},
+		{`int("99999999999999999999")`, `ERROR: could not parse "99999999999999999999" as integer`},
+		{"int(10000000000000000000.0)", "ERROR: argument to `int` out of integer range: 1e+19"},
+		{"int([1])", "ERROR: argument to `int` must be string or number, got ARRAY"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestStr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`str(42) + " apples"`, "42 apples"},
+		{"str(-2.5)", "-2.5"},
+		{"str(true)", "true"},
+		{"str([1, [2], \"a\"])", "[1, [2], a]"},
+		{`str("a")`, "a"},
+		{"str(if (false) { 1 })", "null"},
+		{`int(str(42)) == 42`, "true"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"apply(fn(a, b) { a - b }, [10, 3])", "7"},
+		{"let args = push([], 4); apply(fn(x) { x * x }, args)", "16"},
+		{"apply(fn() { 1 }, [])", "1"},
+		{"apply(len, [[1, 2, 3]])", "3"},
+		{"apply(apply, [fn(x) { x + 1 }, [1]])", "2"},
+		{"apply(fn(a, b) { a }, [1])", "ERROR: wrong number of arguments (expected = 2)"},
+		{"apply(1, [])", "ERROR: first argument to `apply` must be a function, got INTEGER"},
+		{"apply(len, 1)", "ERROR: second argument to `apply` must be array, got INTEGER"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let sub = fn(a, b) { a - b }; let tenMinus = bind(sub, 10); tenMinus(3)", "7"},
+		{"let f = bind(fn(x) { x * 2 }, 4); f()", "8"},
+		{`let greet = bind(format, "%s, %s!"); greet("hello", "world")`, "hello, world!"},
+		{"let add3 = fn(a, b, c) { a + b + c }; bind(bind(add3, 1), 2)(3)", "6"},
+		{"bind(fn(a, b) { a }, 1)()", "ERROR: wrong number of arguments (expected = 2)"},
+		{"bind(1, 2)", "ERROR: first argument to `bind` must be a function, got INTEGER"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       string
+		expectedOutput string
+	}{
+		{
+			`let double = memoize(fn(x) { print(x); x * 2 }); [double(2), double(2), double(3), double(2)]`,
+			"[4, 4, 6, 4]",
+			"2\n3\n",
+		},
+		{
+			`let add = memoize(fn(a, b) { print(a); a + b }); [add(1, 2), add(1, 3), add(1, 2)]`,
+			"[3, 4, 3]",
+			"1\n1\n",
+		},
+		// Arguments that can't be hash keys are recomputed each time
+		{
+			`let size = memoize(fn(a) { print(a); len(a) }); [size([1]), size([1])]`,
+			"[1, 1]",
+			"[1]\n[1]\n",
+		},
+		// Too slow without memoization
+		{
+			"let fib = memoize(fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }); fib(80)",
+			"23416728348467685",
+			"",
+		},
+		{"memoize(1)", "ERROR: argument to `memoize` must be a function, got INTEGER", ""},
+	}
+
+	defer func() { object.Output = os.Stdout }()
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		object.Output = &out
+
+		result := testEval(test.input)
+		assert.Equal(t, test.expectedOutput, out.String(), test.input)
+		assert.Equal(t, test.expected, result.Inspect(), test.input)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\nlet y = foo;", "ERROR: line 2, column 9: identifier not found: foo"},
+		{"let x = 1;\n\n  x + true", "ERROR: line 3, column 5: type mismatch: INTEGER + BOOLEAN"},
+		{"true - false", "ERROR: line 1, column 6: unknown operator: BOOLEAN - BOOLEAN"},
+		// Inside a function, the error is where it happened rather than where the function was called
+		{"let f = fn(x) {\n  x / 0\n};\nf(1)", "ERROR: line 2, column 5: division by zero\n  in f (line 4, column 2)"},
+		{`let s = "a";` + "\nlen(1)", "ERROR: line 2, column 4: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, test := range tests {
+		result, ok := testEval(test.input).(*object.Error)
+		if !ok {
+			t.Fatalf("Expected error for %s", test.input)
+		}
+		assert.Equal(t, test.expected, result.Describe(), test.input)
+	}
+
+	// Errors without a position are described like they're inspected
+	assert.Equal(t, "ERROR: failed", NewError("failed").Describe())
+}
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Errors outside of functions have no trace
+		{"1 + true", "ERROR: line 1, column 3: type mismatch: INTEGER + BOOLEAN"},
+		{"len(1)", "ERROR: line 1, column 4: argument to `len` not supported, got INTEGER"},
+		{
+			"let f = fn(x) {\n  x / 0\n};\nlet g = fn(x) { f(x) };\ng(1)",
+			"ERROR: line 2, column 5: division by zero\n" +
+				"  in f (line 4, column 18)\n" +
+				"  in g (line 5, column 2)",
+		},
+		{
+			"let down = fn(n) {\n  if (n == 0) { foo } else { down(n - 1) }\n};\ndown(2)",
+			"ERROR: line 2, column 17: identifier not found: foo\n" +
+				"  in down (line 2, column 34)\n" +
+				"  in down (line 2, column 34)\n" +
+				"  in down (line 4, column 5)",
+		},
+		// Recursion shows the repeated call once
+		{
+			"let down = fn(n) {\n  if (n == 0) { foo } else { down(n - 1) }\n};\ndown(50)",
+			"ERROR: line 2, column 17: identifier not found: foo\n" +
+				"  in down (line 2, column 34)\n" +
+				"  ... same call 49 more times\n" +
+				"  in down (line 4, column 5)",
+		},
+		{"fn() { len(1) }()", "ERROR: line 1, column 11: argument to `len` not supported, got INTEGER\n  in fn (line 1, column 16)"},
+		{"let f = fn(x) { x + true };\napply(f, [1])", "ERROR: line 1, column 19: type mismatch: INTEGER + BOOLEAN\n  in apply (line 2, column 6)"},
+	}
+
+	for _, test := range tests {
+		result, ok := testEval(test.input).(*object.Error)
+		if !ok {
+			t.Fatalf("Expected error for %s", test.input)
+		}
+		assert.Equal(t, test.expected, result.Describe(), test.input)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`split("a,b,c", ",")`, []string{"a", "b", "c"}},
+		{`split("a, b", ", ")`, []string{"a", "b"}},
+		{`split("a,,b,", ",")`, []string{"a", "", "b", ""}},
+		{`split("abc", ";")`, []string{"abc"}},
+		{`split("héllo", "")`, []string{"h", "é", "l", "l", "o"}},
+		{`split("", ",")`, []string{""}},
+		{`split("", "")`, []string{}},
+	}
+
+	for _, test := range tests {
+		array, ok := testEval(test.input).(*object.Array)
+		if !ok {
+			t.Fatalf("Object isn't array for %s", test.input)
+		}
+
+		actual := []string{}
+		for _, e := range array.Elements {
+			actual = append(actual, e.(*object.String).Value)
+		}
+		assert.Equal(t, test.expected, actual, test.input)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`slice("hello", 1, 3)`, "el"},
+		{`slice("hello", 1)`, "ello"},
+		{`slice("héllo", 1, 2)`, "é"},
+		{`slice("hello", -3, -1)`, "ll"},
+		{`slice("hello", -10, 2)`, "he"},
+		{`slice("hello", 3, 100)`, "lo"},
+		{`slice("hello", 4, 2)`, ""},
+		{`slice("", 0, 1)`, ""},
+		{"slice([1, 2, 3, 4], 1, 3)", "[2, 3]"},
+		{"slice([1, 2, 3, 4], -2)", "[3, 4]"},
+		{"slice([1, 2, 3], 5)", "[]"},
+		{"let a = [1, 2, 3]; let b = slice(a, 0, 2); [len(a), len(b)]", "[3, 2]"},
+		{"slice(1, 0, 1)", "ERROR: first argument to `slice` must be string or array, got INTEGER"},
+		{`slice("abc", "a")`, "ERROR: second argument to `slice` must be integer, got STRING"},
+		{`slice("abc", 0, 1.5)`, "ERROR: third argument to `slice` must be integer, got FLOAT"},
+		{`slice("abc")`, "ERROR: wrong number of arguments (expected 2 to 3)"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abs(-5)", "5"},
+		{"abs(5)", "5"},
+		{"abs(-2.5)", "2.5"},
+		{"min(3, 1, 2)", "1"},
+		{"max(3, 1, 2)", "3"},
+		{"max(7)", "7"},
+		{"max(1, 2.5)", "2.5"},
+		{"min(1, 2.5)", "1"},
+		{"max(9223372036854775807, 9223372036854775806)", "9223372036854775807"},
+		{"abs(-9223372036854775807 - 1)", "ERROR: argument to `abs` out of integer range: -9223372036854775808"},
+		{`abs("a")`, "ERROR: argument to `abs` must be a number, got STRING"},
+		{`max(1, "a")`, "ERROR: arguments to `max` must be numbers, got STRING"},
+		{"min()", "ERROR: wrong number of arguments (expected >= 1)"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`delete({"a": 1, "b": 2}, "a")`, "{b: 2}"},
+		{`delete({"a": 1, "b": 2}, "c")`, "{a: 1, b: 2}"},
+		{`delete({1: "one", true: "yes"}, true)`, "{1: one}"},
+		{`delete({}, 1)`, "{}"},
+		// The original hash isn't changed
+		{`let h = {"a": 1}; let g = delete(h, "a"); [len(h), len(g)]`, "[1, 0]"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, testEval(test.input).Inspect(), test.input)
+	}
+}
+
+// Every builtin the VM knows about resolves in the evaluator too
+// (help is the evaluator's own version, which also knows panic and recover)
+func TestSharedBuiltins(t *testing.T) {
+	for _, def := range object.Builtins {
+		if def.Name == "help" {
+			builtin, ok := testEval(def.Name).(*object.BuiltIn)
+			if !ok {
+				t.Fatalf("help isn't a builtin")
+			}
+			assert.Equal(t, def.Builtin.Signature, builtin.Signature, def.Name)
+			continue
+		}
+		assert.Equal(t, def.Builtin, testEval(def.Name), def.Name)
+	}
+}
+
 func TestBuiltin(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -251,8 +1414,105 @@ func TestBuiltin(t *testing.T) {
 	}{
 		{`len("")`, 0},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`let s = "日本語"; len(s)`, 3},
+		{`let s = "日本語"; s[len(s) - 1]`, &object.String{Value: "語"}},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len({})`, 0},
+		{`values([1])`, "argument to `values` must be HASH, got ARRAY"},
+		{`values({}, {})`, "wrong number of arguments (expected = 1)"},
+		{`delete([1], 0)`, "first argument to `delete` must be HASH, got ARRAY"},
+		{`delete({"a": 1}, [1])`, "unusable as hash key: ARRAY"},
+		{`delete({"a": 1})`, "wrong number of arguments (expected = 2)"},
+		{`split("a,b", 1)`, "arguments to `split` must be strings"},
+		{`split(["a"], ",")`, "arguments to `split` must be strings"},
+		{`join(["a", "b"], ", ")`, &object.String{Value: "a, b"}},
+		{`join(["a"], "-")`, &object.String{Value: "a"}},
+		{`join([], ",")`, &object.String{Value: ""}},
+		{`join(split("a,b,c", ","), "")`, &object.String{Value: "abc"}},
+		{`join("ab", ",")`, "first argument to `join` must be array, got STRING"},
+		{`join(["a"], 1)`, "second argument to `join` must be string, got INTEGER"},
+		{`join(["a", 2], ",")`, "element 1 of array passed to `join` must be string, got INTEGER"},
+		{`len({"a": 1, "a": 2})`, 1},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments (expected = 1)"},
+		{`ord("A")`, 65},
+		{`help("len")`, &object.String{Value: "len(x): Number of characters in a string, elements in an array or pairs in a hash"}},
+		{`help("panic")`, &object.String{Value: "panic(x): Unwind calls with x until recovered by recover() in a deferred expression"}},
+		{`help("recover")`, &object.String{Value: "recover(): Stop the panic being unwound and get its value (null if there is none), in a deferred expression"}},
+		{`help("nope")`, "no builtin named `nope`"},
+		{`help(1)`, "argument to `help` must be string"},
+		{`chr(65)`, &object.String{Value: "A"}},
+		{`chr(ord("a") + 1)`, &object.String{Value: "b"}},
+		{`ord("λ")`, 955},
+		{`ord("AB")`, "argument to `ord` must be single character string"},
+		{`ord(1)`, "argument to `ord` must be single character string"},
+		{`padStart("42", 5, "0")`, &object.String{Value: "00042"}},
+		{`padEnd("42", 5, ".")`, &object.String{Value: "42..."}},
+		{`padStart("λλ", 4, "é")`, &object.String{Value: "ééλλ"}},
+		{`padStart("12345", 3, " ")`, &object.String{Value: "12345"}},
+		{`padStart(str(7), 3, "0")`, &object.String{Value: "007"}},
+		{`padEnd(str(-1.5), 6, " ")`, &object.String{Value: "-1.5  "}},
+		{`padEnd("1", 3, "ab")`, "third argument to `padEnd` must be single character string"},
+		{`padStart(1, 3, " ")`, "first argument to `padStart` must be string"},
+		{`padStart("a", 9223372036854775807, " ")`, "second argument to `padStart` too large: 9223372036854775807"},
+		{`padEnd("a", 9223372036854775807, " ")`, "second argument to `padEnd` too large: 9223372036854775807"},
+		{`padStart("1", 3)`, "wrong number of arguments (expected = 3)"},
+		{`startsWith("hello", "he")`, true},
+		{`startsWith("hello", "lo")`, false},
+		{`startsWith("hello", "")`, true},
+		{`endsWith("hello", "lo")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`endsWith("", "")`, true},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "xyz")`, false},
+		{`contains("hello", "")`, true},
+		{`!startsWith("hello", "x")`, true},
+		{`startsWith("hello", 1)`, "arguments to `startsWith` must be strings"},
+		{`contains("hello")`, "wrong number of arguments (expected = 2)"},
+		{`match("abc123", "[a-z]+[0-9]+")`, true},
+		{`match("abc", "^[0-9]+$")`, false},
+		{`match("abc", "(")`, "invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{`replaceRegex("John Smith", "(\w+) (\w+)", "$2, $1")`, &object.String{Value: "Smith, John"}},
+		{`replaceRegex("a", "b")`, "wrong number of arguments (expected = 3)"},
+		{`chr(-1)`, "argument to `chr` is not a valid code point: -1"},
+		{`chr("A")`, "argument to `chr` must be integer"},
+		{`let a = [1, 2]; same(a, a)`, true},
+		{`let a = [1, 2]; let b = a; same(a, b)`, true},
+		{`same([1, 2], [1, 2])`, false},
+		{`let f = fn() { 1 }; same(f, f)`, true},
+		{`same(fn() { 1 }, fn() { 1 })`, false},
+		{`same({"a": 1}, {"a": 1})`, false},
+		{`same(1 + 1, 2)`, true},
+		{`same("a" + "b", "ab")`, true},
+		{`same(1, "1")`, false},
+		{`same(0.5 + 0.5, 1.0)`, true},
+		{`same(-0.0, 0.0)`, true},
+		{`let nan = 0.0 / 0.0; same(nan, nan)`, false},
+		{`same(1, 1.0)`, false},
+		{`same(true, 1 < 2)`, true},
+		{`same(1)`, "wrong number of arguments (expected = 2)"},
+		{`floor(2.7)`, 2},
+		{`floor(-2.5)`, -3},
+		{`floor(-0.0001)`, -1},
+		{`floor(3)`, 3},
+		{`ceil(2.1)`, 3},
+		{`ceil(-2.9)`, -2},
+		{`ceil(0.0001)`, 1},
+		{`ceil(-4)`, -4},
+		{`round(2.5)`, 3},
+		{`round(2.4999)`, 2},
+		{`round(-2.5)`, -3},
+		{`round(-0.5)`, -1},
+		{`round(7)`, 7},
+		{`trunc(2.9)`, 2},
+		{`trunc(-2.9)`, -2},
+		{`trunc(-0.5)`, 0},
+		{`trunc(5)`, 5},
+		{`floor("1")`, "argument to `floor` must be a number, got STRING"},
+		{`round(0.0 / 0.0)`, "argument to `round` out of integer range: NaN"},
+		{`ceil(1.0 / 0.0)`, "argument to `ceil` out of integer range: +Inf"},
+		{`trunc(1.5, 2)`, "wrong number of arguments (expected = 1)"},
 	}
 
 	for _, test := range tests {
@@ -261,6 +1521,15 @@ func TestBuiltin(t *testing.T) {
 		switch expected := test.expected.(type) {
 		case int:
 			testInteger(t, actual, int64(expected))
+		case bool:
+			testBoolean(t, actual, expected)
+		case *object.String:
+			str, ok := actual.(*object.String)
+			if !ok {
+				t.Fatalf("Object isn't string")
+			}
+
+			assert.Equal(t, expected.Value, str.Value)
 		case string:
 			errObj, ok := actual.(*object.Error)
 
@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"go_interpreter/ast"
+	"go_interpreter/object"
+)
+
+// Move top-level macro definitions (e.g. "let m = macro(x) { x };") out of the program and into env
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	// Remove definitions from the back so earlier indices stay valid
+	for i := len(definitions) - 1; i >= 0; i-- {
+		index := definitions[i]
+		program.Statements = append(program.Statements[:index], program.Statements[index+1:]...)
+	}
+}
+
+// Replace calls to macros with the code they return
+func ExpandMacros(program ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.Call)
+		if !ok {
+			return node
+		}
+
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+
+		args := quoteArgs(call)
+		evalEnv := extendMacroEnv(macro, args)
+
+		evaluated := Eval(macro.Body, evalEnv)
+
+		quote, ok := unwrapReturn(evaluated).(*object.Quote)
+		if !ok {
+			// Macros must return quoted code: leave the call in place
+			return node
+		}
+
+		return quote.Node
+	})
+}
+
+// Helper function for checking if statement is "let <name> = macro(...) {...};"
+func isMacroDefinition(statement ast.Statement) bool {
+	let, ok := statement.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+
+	_, ok = let.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+// Helper function for binding a macro definition in env
+func addMacro(statement ast.Statement, env *object.Environment) {
+	let, _ := statement.(*ast.LetStatement)
+	literal, _ := let.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{Parameters: literal.Parameters, Body: literal.Body, Env: env}
+	env.Set(let.Name.Value, macro)
+}
+
+// Helper function for looking up the macro being called (if any)
+func isMacroCall(call *ast.Call, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+// Helper function for passing arguments to macros unevaluated
+func quoteArgs(call *ast.Call) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, a := range call.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+// Helper function for binding quoted arguments to macro parameter names
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.BuildFunctionEnvironment(macro.Env)
+
+	for i, p := range macro.Parameters {
+		if i < len(args) {
+			extended.Set(p.Value, args[i])
+		}
+	}
+
+	return extended
+}
@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go_interpreter/ast"
+	"go_interpreter/lexer"
+	"go_interpreter/object"
+	"go_interpreter/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.BuildEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	assert.Equal(t, 2, len(program.Statements), "Expected number of statements")
+
+	_, ok := env.Get("number")
+	assert.False(t, ok, "number should not be defined")
+	_, ok = env.Get("function")
+	assert.False(t, ok, "function should not be defined")
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("Expected *object.Macro, actual: %T (%+v)", obj, obj)
+	}
+
+	assert.Equal(t, 2, len(macro.Parameters))
+	assert.Equal(t, "x", macro.Parameters[0].String())
+	assert.Equal(t, "y", macro.Parameters[1].String())
+	assert.Equal(t, "(x + y)", macro.Body.String())
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, print("not greater"), print("greater"));`,
+			`if (!(10 > 5)) { print("not greater") } else { print("greater") }`,
+		},
+	}
+
+	for _, test := range tests {
+		expected := testParseProgram(test.expected)
+		program := testParseProgram(test.input)
+
+		env := object.BuildEnvironment()
+		DefineMacros(program, env)
+		expanded := ExpandMacros(program, env)
+
+		assert.Equal(t, expected.String(), expanded.String(), test.input)
+	}
+}
+
+func TestUnlessMacro(t *testing.T) {
+	input := `
+	let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) {
+			unquote(consequence);
+		} else {
+			unquote(alternative);
+		});
+	};
+	unless(10 > 5, "not greater", "greater");
+	`
+
+	program := testParseProgram(input)
+	macroEnv := object.BuildEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded := ExpandMacros(program, macroEnv)
+
+	result := Eval(expanded, object.BuildEnvironment())
+	str, ok := result.(*object.String)
+	if !ok {
+		t.Fatalf("Expected *object.String, actual: %T (%+v)", result, result)
+	}
+	assert.Equal(t, "greater", str.Value)
+}
+
+func TestNestedMacroDefinition(t *testing.T) {
+	program := testParseProgram("let f = fn() { let m = macro(x) { x }; }; f();")
+	env := object.BuildEnvironment()
+	DefineMacros(program, env)
+
+	result := Eval(ExpandMacros(program, env), object.BuildEnvironment())
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("Expected *object.Error, actual: %T (%+v)", result, result)
+	}
+	assert.Equal(t, "macro definitions must be top-level let statements", errObj.Message)
+}
+
+// Helper function to parse a program
+func testParseProgram(input string) *ast.Program {
+	l := lexer.BuildLexer(input)
+	p := parser.BuildParser(l)
+	return p.ParseProgram()
+}
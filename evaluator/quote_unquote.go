@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"go_interpreter/ast"
+	"go_interpreter/object"
+	"go_interpreter/token"
+	"strconv"
+)
+
+// Helper method for wrapping an unevaluated node, after evaluating the unquote calls inside it
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node = evalUnquoteCalls(node, env)
+	return &object.Quote{Node: node}
+}
+
+// Helper method for replacing each unquote(x) call with the value of x
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(quoted, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.Call)
+		if !ok || !isSpecialForm(call, "unquote", env) || len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		return convertObjectToNode(unquoted, node)
+	})
+}
+
+// Helper function for turning an evaluated value back into a node (node is kept if there's no literal form)
+func convertObjectToNode(obj object.Object, node ast.Node) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10), Line: node.Line(), Column: node.Column()}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect(), Line: node.Line(), Column: node.Column()}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Line: node.Line(), Column: node.Column()}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false", Line: node.Line(), Column: node.Column()}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Line: node.Line(), Column: node.Column()}
+		return &ast.String{Token: t, Value: obj.Value}
+	case *object.Quote:
+		return obj.Node
+	default:
+		return node
+	}
+}
@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go_interpreter/object"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "5"},
+		{"quote(5 + 8)", "(5 + 8)"},
+		{"quote(foobar)", "foobar"},
+		{"quote(foobar + barfoo)", "(foobar + barfoo)"},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("Expected *object.Quote, actual: %T (%+v)", evaluated, evaluated)
+		}
+
+		assert.Equal(t, test.expected, quote.Node.String(), test.input)
+	}
+}
+
+func TestUnquoteSplicesLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(unquote(1 + 2))", "quote(3)"},
+		{"quote(unquote(1 < 2))", "quote(true)"},
+		{`quote(unquote("a" + "b"))`, `quote("ab")`},
+	}
+
+	for _, test := range tests {
+		actual := testEval(test.input).(*object.Quote)
+		expected := testEval(test.expected).(*object.Quote)
+
+		assert.IsType(t, expected.Node, actual.Node, test.input)
+		assert.Equal(t, expected.Node.String(), actual.Node.String(), test.input)
+
+		// Spliced literal evaluates to the unquoted value
+		assert.Equal(t, Eval(expected.Node, object.BuildEnvironment()).Inspect(),
+			Eval(actual.Node, object.BuildEnvironment()).Inspect(), test.input)
+	}
+}
+
+func TestQuoteIsNotEvaluated(t *testing.T) {
+	evaluated := testEval("quote(1 + 2)")
+
+	assert.Equal(t, object.ObjectType(object.QUOTE_OBJECT), evaluated.Type())
+	assert.Equal(t, "(1 + 2)", evaluated.Inspect())
+
+	for _, input := range []string{"quote()", "quote(1, 2)"} {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok {
+			t.Fatalf("Expected *object.Error for %s", input)
+		}
+		assert.Equal(t, "wrong number of arguments (expected = 1)", errObj.Message, input)
+	}
+
+	// A user-defined quote is an ordinary function
+	testInteger(t, testEval("let quote = fn(x) { x }; quote(1 + 2)"), 3)
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(unquote(4))", "4"},
+		{"quote(unquote(4 + 4))", "8"},
+		{"quote(8 + unquote(4 + 4))", "(8 + 8)"},
+		{"quote(unquote(4 + 4) + 8)", "(8 + 8)"},
+		{"let foobar = 8; quote(foobar)", "foobar"},
+		{"let foobar = 8; quote(unquote(foobar))", "8"},
+		{"quote(unquote(true))", "true"},
+		{"quote(unquote(true == false))", "false"},
+		{"quote(unquote(quote(4 + 4)))", "(4 + 4)"},
+		{"let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))", "(8 + (4 + 4))"},
+		{`quote(unquote("a" + "b"))`, "ab"},
+		{`let s = "x"; quote(len(unquote(s)))`, "len(x)"},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("Expected *object.Quote, actual: %T (%+v)", evaluated, evaluated)
+		}
+
+		assert.Equal(t, test.expected, quote.Node.String(), test.input)
+	}
+}
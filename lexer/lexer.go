@@ -2,55 +2,110 @@ package lexer
 
 import (
 	"go_interpreter/token"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Converts source code to tokens
 type Lexer struct {
-	input           string
-	currentPosition int  // position that lexer points to in input
-	nextPosition    int  // next position after current position
-	currentChar     byte // character at current position
+	input        string        // Source code (unless reading from reader)
+	reader       io.RuneReader // Source code read on demand (nil if lexing input)
+	err          error         // First error from reader, other than io.EOF
+	nextPosition int           // position in input after nextChar
+	currentChar  rune          // character lexer points to
+	nextChar     rune          // character after current character
+	line         int           // line of current character
+	column       int           // column of current character (in characters)
 }
 
 func BuildLexer(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	lexer := &Lexer{input: input, line: 1}
 
-	// Initialize currentPosition, nextPosition, currentChar
+	// Initialize currentChar, nextChar
+	lexer.nextChar = lexer.readCharacter()
 	lexer.advanceCharacter()
 
 	return lexer
 }
 
+// Lexer reading source code from r as tokens are requested, without holding all of it in memory
+func BuildReaderLexer(r io.RuneReader) *Lexer {
+	lexer := &Lexer{reader: r, line: 1}
+
+	// Initialize currentChar, nextChar
+	lexer.nextChar = lexer.readCharacter()
+	lexer.advanceCharacter()
+
+	return lexer
+}
+
+// Same as BuildReaderLexer
+func NewReaderLexer(r io.RuneReader) *Lexer {
+	return BuildReaderLexer(r)
+}
+
+// Get the error that stopped reading source code (nil if the whole source was read)
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Read character from source code (null character at the end)
+func (l *Lexer) readCharacter() rune {
+	if l.reader != nil {
+		if l.err != nil {
+			return 0
+		}
+
+		ch, _, err := l.reader.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			return 0
+		}
+		return ch
+	}
+
+	if l.nextPosition >= len(l.input) {
+		return 0 // ASCII code for null character
+	}
+
+	ch, width := utf8.DecodeRuneInString(l.input[l.nextPosition:])
+	l.nextPosition += width
+	return ch
+}
+
 // Read next character and advance lexer
 func (l *Lexer) advanceCharacter() {
-	if l.nextPosition >= len(l.input) {
-		l.currentChar = 0 // ASCII code for null character
-	} else {
-		l.currentChar = l.input[l.nextPosition]
+	if l.currentChar == '\n' {
+		l.line += 1
+		l.column = 0
 	}
 
-	l.currentPosition = l.nextPosition
-	l.nextPosition += 1
+	l.currentChar = l.nextChar
+	l.column += 1
+	if l.currentChar != 0 {
+		l.nextChar = l.readCharacter()
+	}
 }
 
 // Read next token and advance lexer
-func (l *Lexer) advanceToken(constraint func(byte) bool) string {
-	startPosition := l.currentPosition
+func (l *Lexer) advanceToken(constraint func(rune) bool) string {
+	var out strings.Builder
 
 	for constraint(l.currentChar) {
+		out.WriteRune(l.currentChar)
 		l.advanceCharacter()
 	}
 
-	return l.input[startPosition:l.currentPosition]
+	return out.String()
 }
 
 // Read next character, but without advancing lexer
-func (l *Lexer) peekCharacter() byte {
-	if l.nextPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.nextPosition]
-	}
+func (l *Lexer) peekCharacter() rune {
+	return l.nextChar
 }
 
 // Skip whitespace in between tokens
@@ -66,94 +121,152 @@ func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
 	var t token.Token
+	line, column := l.line, l.column
 
 	switch l.currentChar {
 	case '=':
 		if l.peekCharacter() == '=' {
 			l.advanceCharacter()
-			t = token.Token{token.EQ, string("=" + string(l.currentChar))}
+			t = token.Token{Type: token.EQ, Literal: string("=" + string(l.currentChar))}
+		} else if l.peekCharacter() == '>' {
+			l.advanceCharacter()
+			t = token.Token{Type: token.ARROW, Literal: string("=" + string(l.currentChar))}
 		} else {
-			t = token.Token{token.ASSIGN, string(l.currentChar)}
+			t = token.Token{Type: token.ASSIGN, Literal: string(l.currentChar)}
 		}
 	case '!':
 		if l.peekCharacter() == '=' {
 			l.advanceCharacter()
-			t = token.Token{token.NOT_EQ, string("!" + string(l.currentChar))}
+			t = token.Token{Type: token.NOT_EQ, Literal: string("!" + string(l.currentChar))}
 		} else {
-			t = token.Token{token.BANG, string(l.currentChar)}
+			t = token.Token{Type: token.BANG, Literal: string(l.currentChar)}
 		}
 	case ';':
-		t = token.Token{token.SEMICOLON, string(l.currentChar)}
+		t = token.Token{Type: token.SEMICOLON, Literal: string(l.currentChar)}
 	case '(':
-		t = token.Token{token.LPAREN, string(l.currentChar)}
+		t = token.Token{Type: token.LPAREN, Literal: string(l.currentChar)}
 	case ')':
-		t = token.Token{token.RPAREN, string(l.currentChar)}
+		t = token.Token{Type: token.RPAREN, Literal: string(l.currentChar)}
 	case ',':
-		t = token.Token{token.COMMA, string(l.currentChar)}
+		t = token.Token{Type: token.COMMA, Literal: string(l.currentChar)}
 	case '+':
-		t = token.Token{token.PLUS, string(l.currentChar)}
+		t = token.Token{Type: token.PLUS, Literal: string(l.currentChar)}
 	case '{':
-		t = token.Token{token.LBRACE, string(l.currentChar)}
+		t = token.Token{Type: token.LBRACE, Literal: string(l.currentChar)}
 	case '}':
-		t = token.Token{token.RBRACE, string(l.currentChar)}
+		t = token.Token{Type: token.RBRACE, Literal: string(l.currentChar)}
 	case '-':
-		t = token.Token{token.MINUS, string(l.currentChar)}
+		t = token.Token{Type: token.MINUS, Literal: string(l.currentChar)}
 	case '/':
-		t = token.Token{token.SLASH, string(l.currentChar)}
+		t = token.Token{Type: token.SLASH, Literal: string(l.currentChar)}
 	case '*':
-		t = token.Token{token.ASTERISK, string(l.currentChar)}
+		t = token.Token{Type: token.ASTERISK, Literal: string(l.currentChar)}
+	case '%':
+		t = token.Token{Type: token.PERCENT, Literal: string(l.currentChar)}
 	case '<':
-		t = token.Token{token.LT, string(l.currentChar)}
+		if l.peekCharacter() == '=' {
+			l.advanceCharacter()
+			t = token.Token{Type: token.LT_EQ, Literal: string("<" + string(l.currentChar))}
+		} else {
+			t = token.Token{Type: token.LT, Literal: string(l.currentChar)}
+		}
 	case '>':
-		t = token.Token{token.GT, string(l.currentChar)}
+		if l.peekCharacter() == '=' {
+			l.advanceCharacter()
+			t = token.Token{Type: token.GT_EQ, Literal: string(">" + string(l.currentChar))}
+		} else {
+			t = token.Token{Type: token.GT, Literal: string(l.currentChar)}
+		}
 	case '"':
-		t = token.Token{token.STRING, l.readString()}
+		t = token.Token{Type: token.STRING, Literal: l.readString()}
 	case '[':
-		t = token.Token{token.LSQUARE, string(l.currentChar)}
+		t = token.Token{Type: token.LSQUARE, Literal: string(l.currentChar)}
 	case ']':
-		t = token.Token{token.RSQUARE, string(l.currentChar)}
+		t = token.Token{Type: token.RSQUARE, Literal: string(l.currentChar)}
 	case ':':
-		t = token.Token{token.COLON, string(l.currentChar)}
+		t = token.Token{Type: token.COLON, Literal: string(l.currentChar)}
+	case '|':
+		if l.peekCharacter() == '>' {
+			l.advanceCharacter()
+			t = token.Token{Type: token.PIPE, Literal: string("|" + string(l.currentChar))}
+		} else if l.peekCharacter() == '|' {
+			l.advanceCharacter()
+			t = token.Token{Type: token.OR, Literal: string("|" + string(l.currentChar))}
+		} else {
+			t = token.Token{Type: token.ILLEGAL, Literal: string(l.currentChar)}
+		}
+	case '&':
+		if l.peekCharacter() == '&' {
+			l.advanceCharacter()
+			t = token.Token{Type: token.AND, Literal: string("&" + string(l.currentChar))}
+		} else {
+			t = token.Token{Type: token.ILLEGAL, Literal: string(l.currentChar)}
+		}
 	case 0:
-		t = token.Token{token.EOF, ""}
+		t = token.Token{Type: token.EOF, Literal: ""}
 	default:
 		if isLetter(l.currentChar) {
-			t.Literal = l.advanceToken(isLetter)
+			t.Literal = l.advanceToken(isIdentifierCharacter)
 			t.Type = token.GetIdentifier(t.Literal)
+			t.Line = line
+			t.Column = column
 			return t
 		} else if isDigit(l.currentChar) {
-			t.Literal = l.advanceToken(isDigit)
-			t.Type = token.INT
+			t.Literal, t.Type = l.readNumber()
+			t.Line = line
+			t.Column = column
 			return t
 		} else {
-			t = token.Token{token.ILLEGAL, string(l.currentChar)}
+			t = token.Token{Type: token.ILLEGAL, Literal: string(l.currentChar)}
 		}
 	}
 
 	l.advanceCharacter()
+	t.Line = line
+	t.Column = column
 	return t
 }
 
 // Helper function
 func (l *Lexer) readString() string {
-	startPosition := l.currentPosition + 1
+	var out strings.Builder
 
 	for {
 		l.advanceCharacter()
 		if l.currentChar == '"' || l.currentChar == 0 {
 			break
 		}
+		out.WriteRune(l.currentChar)
 	}
 
-	return l.input[startPosition:l.currentPosition]
+	return out.String()
 }
 
-// Helper function
-func isLetter(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+// Helper function (integer, or float if digits are followed by "." and more digits)
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	digits := l.advanceToken(isDigit)
+
+	if l.currentChar != '.' || !isDigit(l.peekCharacter()) {
+		return digits, token.INT
+	}
+
+	// "."
+	l.advanceCharacter()
+
+	return digits + "." + l.advanceToken(isDigit), token.FLOAT
+}
+
+// Helper function (any unicode letter)
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
+// Helper function (letters and digits after first character of identifier)
+func isIdentifierCharacter(ch rune) bool {
+	return isLetter(ch) || unicode.IsDigit(ch)
 }
 
 // Helper function
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"go_interpreter/token"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -83,7 +87,7 @@ func TestAddFunction(t *testing.T) {
 }
 
 func TestSingleCharacterTokens(t *testing.T) {
-	input := `!-/*5;
+	input := `!-/*%5;
 						5 < 10 > 5`
 
 	expectedTokens := []struct {
@@ -94,6 +98,7 @@ func TestSingleCharacterTokens(t *testing.T) {
 		{token.MINUS, "-"},
 		{token.SLASH, "/"},
 		{token.ASTERISK, "*"},
+		{token.PERCENT, "%"},
 		{token.INT, "5"},
 		{token.SEMICOLON, ";"},
 		{token.INT, "5"},
@@ -144,6 +149,10 @@ func TestMultiCharacterTokens(t *testing.T) {
 func TestDoubleCharacterTokens(t *testing.T) {
 	input := `10 == 10;
 						10 != 9;
+						1 <= 2 >= 3;
+						fn(x) => x;
+						x |> f | g;
+						a && b || c & d;
 						"foobar"
 						"foo bar"`
 
@@ -159,6 +168,33 @@ func TestDoubleCharacterTokens(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.LT_EQ, "<="},
+		{token.INT, "2"},
+		{token.GT_EQ, ">="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "g"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "d"},
+		{token.SEMICOLON, ";"},
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
 		{token.EOF, ""},
@@ -167,6 +203,123 @@ func TestDoubleCharacterTokens(t *testing.T) {
 	testLexer(t, input, expectedTokens)
 }
 
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let π = 3; λ + café_2 + x1 é`
+
+	expectedTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "π"},
+		{token.ASSIGN, "="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "λ"},
+		{token.PLUS, "+"},
+		{token.IDENT, "café_2"},
+		{token.PLUS, "+"},
+		{token.IDENT, "x1"},
+		{token.IDENT, "é"},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, expectedTokens)
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 3.14 0.5 10.0 1.x 2.`
+
+	expectedTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "10.0"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, expectedTokens)
+}
+
+func TestLineNumbers(t *testing.T) {
+	input := "let x = 5;\n\nlet y = \"a\nb\";\n  x"
+
+	expectedLines := []int{1, 1, 1, 1, 1, 3, 3, 3, 3, 4, 5, 5}
+
+	l := BuildLexer(input)
+	for _, expectedLine := range expectedLines {
+		actualToken := l.NextToken()
+		assert.Equal(t, expectedLine, actualToken.Line, actualToken.Literal)
+	}
+}
+
+func TestColumnNumbers(t *testing.T) {
+	input := "let x = 5;\n  x == \"é\" + y"
+
+	expectedColumns := []int{1, 5, 7, 9, 10, 3, 5, 8, 12, 14, 15}
+
+	l := BuildLexer(input)
+	for _, expectedColumn := range expectedColumns {
+		actualToken := l.NextToken()
+		assert.Equal(t, expectedColumn, actualToken.Column, actualToken.Literal)
+	}
+}
+
+func TestReaderLexer(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+let π = 3.14;
+let s = "multi
+line";
+if (add(1, 2) >= 3 && !false || x != y) { [1, 2][0] % 2 } else { {"k": s}["k"] }
+x |> f; 5 <= 6; 1.x`
+
+	expected := BuildLexer(input)
+	actual := NewReaderLexer(bufio.NewReader(strings.NewReader(input)))
+
+	for {
+		expectedToken := expected.NextToken()
+		assert.Equal(t, expectedToken, actual.NextToken())
+
+		if expectedToken.Type == token.EOF {
+			break
+		}
+	}
+
+	// Stays at EOF
+	assert.EqualValues(t, token.EOF, actual.NextToken().Type)
+	assert.Nil(t, actual.Err())
+}
+
+// Reader failing after the given input
+type failingReader struct {
+	input *strings.Reader
+}
+
+func (r *failingReader) ReadRune() (rune, int, error) {
+	ch, size, err := r.input.ReadRune()
+	if err == io.EOF {
+		return 0, 0, errors.New("read failed")
+	}
+	return ch, size, err
+}
+
+func TestReaderLexerError(t *testing.T) {
+	l := BuildReaderLexer(&failingReader{strings.NewReader("let x")})
+
+	assert.EqualValues(t, token.LET, l.NextToken().Type)
+	assert.Equal(t, "x", l.NextToken().Literal)
+	assert.EqualValues(t, token.EOF, l.NextToken().Type)
+	assert.EqualError(t, l.Err(), "read failed")
+}
+
 func testLexer(t *testing.T, input string, expectedTokens []struct {
 	expectedType    token.TokenType
 	expectedLiteral string
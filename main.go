@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go_interpreter/object"
 	"go_interpreter/repl"
 	"os"
 	"os/user"
@@ -11,8 +12,20 @@ import (
 func main() {
 	// Interpreter or compiler
 	engine := flag.String("engine", "vm", "use 'vm' or 'eval'")
+	sandbox := flag.Bool("sandbox", false, "disallow builtins that touch the file system")
 	flag.Parse()
 
+	object.Sandbox = *sandbox
+
+	// Run script file if given, otherwise start REPL
+	if flag.NArg() > 0 {
+		err := repl.RunFile(engine, flag.Arg(0), os.Stdout)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Get user
 	user, err := user.Current()
 	if err != nil {
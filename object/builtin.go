@@ -1,6 +1,23 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Where builtins like print write to
+var Output io.Writer = os.Stdout
+
+// When set, builtins that touch the file system (like readLines) return an error
+var Sandbox = false
 
 var Builtins = []struct {
 	Name    string
@@ -9,16 +26,18 @@ var Builtins = []struct {
 	{
 		"len",
 		&BuiltIn{
+			Signature: "len(x)",
+			Doc:       "Number of characters in a string, elements in an array or pairs in a hash",
+			MinArgs:   1,
+			MaxArgs:   1,
 			Function: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments (expected = 1)")
-				}
-
 				switch arg := args[0].(type) {
 				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
+					return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 				case *Array:
 					return &Integer{Value: int64(len(arg.Elements))}
+				case *Hash:
+					return &Integer{Value: int64(len(arg.Pairs))}
 				default:
 					return newError("argument to `len` not supported, got %s", args[0].Type())
 				}
@@ -28,11 +47,11 @@ var Builtins = []struct {
 	{
 		"first",
 		&BuiltIn{
+			Signature: "first(array)",
+			Doc:       "First element of an array (null if empty)",
+			MinArgs:   1,
+			MaxArgs:   1,
 			Function: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments (expected = 1)")
-				}
-
 				if args[0].Type() != ARRAY_OBJECT {
 					return newError("argument to `first` must be array")
 				}
@@ -49,11 +68,11 @@ var Builtins = []struct {
 	{
 		"last",
 		&BuiltIn{
+			Signature: "last(array)",
+			Doc:       "Last element of an array (null if empty)",
+			MinArgs:   1,
+			MaxArgs:   1,
 			Function: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments (expected = 1)")
-				}
-
 				if args[0].Type() != ARRAY_OBJECT {
 					return newError("argument to `first` must be array")
 				}
@@ -70,11 +89,11 @@ var Builtins = []struct {
 	{
 		"tail",
 		&BuiltIn{
+			Signature: "tail(array)",
+			Doc:       "New array with every element but the first (null if empty)",
+			MinArgs:   1,
+			MaxArgs:   1,
 			Function: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments (expected = 1)")
-				}
-
 				if args[0].Type() != ARRAY_OBJECT {
 					return newError("argument to `first` must be array")
 				}
@@ -95,11 +114,11 @@ var Builtins = []struct {
 	{
 		"push",
 		&BuiltIn{
+			Signature: "push(array, x)",
+			Doc:       "New array with x added to the end",
+			MinArgs:   2,
+			MaxArgs:   2,
 			Function: func(args ...Object) Object {
-				if len(args) != 2 {
-					return newError("wrong number of arguments (expected = 2)")
-				}
-
 				if args[0].Type() != ARRAY_OBJECT {
 					return newError("argument to `first` must be array")
 				}
@@ -117,20 +136,1053 @@ var Builtins = []struct {
 	{
 		"print",
 		&BuiltIn{
+			Signature: "print(x, ...)",
+			Doc:       "Print each argument on its own line",
+			MinArgs:   0,
+			MaxArgs:   -1,
+			Function: func(args ...Object) Object {
+				for _, arg := range args {
+					fmt.Fprintln(Output, arg.Inspect())
+				}
+				return nil
+			},
+		},
+	},
+	{
+		"ord",
+		&BuiltIn{
+			Signature: "ord(char)",
+			Doc:       "Code point of a single character string",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				str, ok := args[0].(*String)
+				if !ok || utf8.RuneCountInString(str.Value) != 1 {
+					return newError("argument to `ord` must be single character string")
+				}
+
+				r, _ := utf8.DecodeRuneInString(str.Value)
+				return &Integer{Value: int64(r)}
+			},
+		},
+	},
+	{
+		"chr",
+		&BuiltIn{
+			Signature: "chr(code)",
+			Doc:       "Single character string for a code point",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				if args[0].Type() != INTEGER_OBJECT {
+					return newError("argument to `chr` must be integer")
+				}
+
+				value := args[0].(*Integer).Value
+				if value < 0 || value > utf8.MaxRune || !utf8.ValidRune(rune(value)) {
+					return newError("argument to `chr` is not a valid code point: %d", value)
+				}
+
+				return &String{Value: string(rune(value))}
+			},
+		},
+	},
+	{
+		"startsWith",
+		&BuiltIn{
+			Signature: "startsWith(s, prefix)",
+			Doc:       "Whether s starts with prefix",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				s, prefix, err := getStringArguments("startsWith", args)
+				if err != nil {
+					return err
+				}
+
+				return toBoolean(strings.HasPrefix(s, prefix))
+			},
+		},
+	},
+	{
+		"endsWith",
+		&BuiltIn{
+			Signature: "endsWith(s, suffix)",
+			Doc:       "Whether s ends with suffix",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				s, suffix, err := getStringArguments("endsWith", args)
+				if err != nil {
+					return err
+				}
+
+				return toBoolean(strings.HasSuffix(s, suffix))
+			},
+		},
+	},
+	{
+		"contains",
+		&BuiltIn{
+			Signature: "contains(s, sub)",
+			Doc:       "Whether sub is a substring of s",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				s, sub, err := getStringArguments("contains", args)
+				if err != nil {
+					return err
+				}
+
+				return toBoolean(strings.Contains(s, sub))
+			},
+		},
+	},
+	{
+		"padStart",
+		&BuiltIn{
+			Signature: "padStart(s, width, char)",
+			Doc:       "Pad the start of s with char up to width characters",
+			MinArgs:   3,
+			MaxArgs:   3,
+			Function: func(args ...Object) Object {
+				padding, err := getPadding("padStart", args)
+				if err != nil {
+					return err
+				}
+
+				return &String{Value: padding + args[0].(*String).Value}
+			},
+		},
+	},
+	{
+		"padEnd",
+		&BuiltIn{
+			Signature: "padEnd(s, width, char)",
+			Doc:       "Pad the end of s with char up to width characters",
+			MinArgs:   3,
+			MaxArgs:   3,
+			Function: func(args ...Object) Object {
+				padding, err := getPadding("padEnd", args)
+				if err != nil {
+					return err
+				}
+
+				return &String{Value: args[0].(*String).Value + padding}
+			},
+		},
+	},
+	{
+		"match",
+		&BuiltIn{
+			Signature: "match(s, pattern)",
+			Doc:       "Whether s matches the regular expression pattern",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				s, pattern, err := getStringArguments("match", args)
+				if err != nil {
+					return err
+				}
+
+				re, err := compileRegex(pattern)
+				if err != nil {
+					return err
+				}
+
+				return toBoolean(re.MatchString(s))
+			},
+		},
+	},
+	{
+		"replaceRegex",
+		&BuiltIn{
+			Signature: "replaceRegex(s, pattern, replacement)",
+			Doc:       "Replace every match of pattern in s",
+			MinArgs:   3,
+			MaxArgs:   3,
+			Function: func(args ...Object) Object {
+				s, pattern, err := getStringArguments("replaceRegex", args[:2])
+				if err != nil {
+					return err
+				}
+
+				replacement, ok := args[2].(*String)
+				if !ok {
+					return newError("arguments to `replaceRegex` must be strings")
+				}
+
+				re, err := compileRegex(pattern)
+				if err != nil {
+					return err
+				}
+
+				return &String{Value: re.ReplaceAllString(s, replacement.Value)}
+			},
+		},
+	},
+	{
+		"same",
+		&BuiltIn{
+			Signature: "same(a, b)",
+			Doc:       "Whether a and b are the same object (integers and strings by value, anything else by reference)",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				return toBoolean(same(args[0], args[1]))
+			},
+		},
+	},
+	{
+		"floor",
+		&BuiltIn{
+			Signature: "floor(x)",
+			Doc:       "Largest integer <= x",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				return roundToInteger("floor", args[0], math.Floor)
+			},
+		},
+	},
+	{
+		"ceil",
+		&BuiltIn{
+			Signature: "ceil(x)",
+			Doc:       "Smallest integer >= x",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				return roundToInteger("ceil", args[0], math.Ceil)
+			},
+		},
+	},
+	{
+		"round",
+		&BuiltIn{
+			Signature: "round(x)",
+			Doc:       "Nearest integer to x, halves rounded away from zero (round(2.5) is 3)",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				return roundToInteger("round", args[0], math.Round)
+			},
+		},
+	},
+	{
+		"trunc",
+		&BuiltIn{
+			Signature: "trunc(x)",
+			Doc:       "Integer part of x, rounded towards zero",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				return roundToInteger("trunc", args[0], math.Trunc)
+			},
+		},
+	},
+	{
+		"values",
+		&BuiltIn{
+			Signature: "values(hash)",
+			Doc:       "New array with the values of a hash (ordered by key: numbers, then booleans, then strings)",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				hash, ok := args[0].(*Hash)
+				if !ok {
+					return newError("argument to `values` must be HASH, got %s", args[0].Type())
+				}
+
+				values := []Object{}
+				for _, pair := range sortedPairs(hash) {
+					values = append(values, pair.Value)
+				}
+				return &Array{Elements: values}
+			},
+		},
+	},
+	{
+		"delete",
+		&BuiltIn{
+			Signature: "delete(hash, key)",
+			Doc:       "New hash without key (hash itself isn't changed)",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				hash, ok := args[0].(*Hash)
+				if !ok {
+					return newError("first argument to `delete` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := AsHashKey(args[1])
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				removed := key.HashKey()
+				pairs := make(map[HashKey]HashPair)
+				for k, pair := range hash.Pairs {
+					if k != removed {
+						pairs[k] = pair
+					}
+				}
+				return &Hash{Pairs: pairs}
+			},
+		},
+	},
+	{
+		"split",
+		&BuiltIn{
+			Signature: "split(s, sep)",
+			Doc:       "Array of the pieces of s between each sep (characters of s if sep is empty)",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				s, sep, err := getStringArguments("split", args)
+				if err != nil {
+					return err
+				}
+
+				pieces := []Object{}
+				for _, piece := range strings.Split(s, sep) {
+					pieces = append(pieces, &String{Value: piece})
+				}
+				return &Array{Elements: pieces}
+			},
+		},
+	},
+	{
+		"join",
+		&BuiltIn{
+			Signature: "join(array, sep)",
+			Doc:       "String of the strings in array, with sep between each one",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				array, ok := args[0].(*Array)
+				if !ok {
+					return newError("first argument to `join` must be array, got %s", args[0].Type())
+				}
+
+				sep, ok := args[1].(*String)
+				if !ok {
+					return newError("second argument to `join` must be string, got %s", args[1].Type())
+				}
+
+				pieces := []string{}
+				for i, e := range array.Elements {
+					piece, ok := e.(*String)
+					if !ok {
+						return newError("element %d of array passed to `join` must be string, got %s", i, e.Type())
+					}
+					pieces = append(pieces, piece.Value)
+				}
+				return &String{Value: strings.Join(pieces, sep.Value)}
+			},
+		},
+	},
+	{
+		"slice",
+		&BuiltIn{
+			Signature: "slice(x, start, end)",
+			Doc:       "Characters of a string or elements of an array from start up to (not including) end, which defaults to the length (negative indices count from the end)",
+			MinArgs:   2,
+			MaxArgs:   3,
+			Function: func(args ...Object) Object {
+				var length int64
+				switch arg := args[0].(type) {
+				case *String:
+					length = int64(utf8.RuneCountInString(arg.Value))
+				case *Array:
+					length = int64(len(arg.Elements))
+				default:
+					return newError("first argument to `slice` must be string or array, got %s", args[0].Type())
+				}
+
+				start, ok := args[1].(*Integer)
+				if !ok {
+					return newError("second argument to `slice` must be integer, got %s", args[1].Type())
+				}
+				end := &Integer{Value: length}
+				if len(args) == 3 {
+					end, ok = args[2].(*Integer)
+					if !ok {
+						return newError("third argument to `slice` must be integer, got %s", args[2].Type())
+					}
+				}
+
+				from := clampIndex(start.Value, length)
+				to := clampIndex(end.Value, length)
+				if to < from {
+					to = from
+				}
+
+				if s, ok := args[0].(*String); ok {
+					return &String{Value: string([]rune(s.Value)[from:to])}
+				}
+
+				elements := make([]Object, to-from)
+				copy(elements, args[0].(*Array).Elements[from:to])
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		"format",
+		&BuiltIn{
+			Signature: "format(template, x, ...)",
+			Doc:       "String of template with each %s replaced by the next argument (%d for integers, %% for %)",
+			MinArgs:   1,
+			MaxArgs:   -1,
+			Function: func(args ...Object) Object {
+				s, err := formatString("format", args)
+				if err != nil {
+					return err
+				}
+				return &String{Value: s}
+			},
+		},
+	},
+	{
+		"printf",
+		&BuiltIn{
+			Signature: "printf(template, x, ...)",
+			Doc:       "Print template formatted like format, without a newline",
+			MinArgs:   1,
+			MaxArgs:   -1,
+			Function: func(args ...Object) Object {
+				s, err := formatString("printf", args)
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(Output, s)
+				return nil
+			},
+		},
+	},
+	{
+		"println",
+		&BuiltIn{
+			Signature: "println(x, ...)",
+			Doc:       "Print the arguments on one line, separated by spaces",
+			MinArgs:   0,
+			MaxArgs:   -1,
 			Function: func(args ...Object) Object {
+				values := []string{}
 				for _, arg := range args {
-					fmt.Println(arg.Inspect())
+					values = append(values, arg.Inspect())
 				}
+				fmt.Fprintln(Output, strings.Join(values, " "))
 				return nil
 			},
 		},
 	},
+	{
+		"abs",
+		&BuiltIn{
+			Signature: "abs(x)",
+			Doc:       "Absolute value of x",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				switch arg := args[0].(type) {
+				case *Integer:
+					if arg.Value == math.MinInt64 {
+						return newError("argument to `abs` out of integer range: %s", arg.Inspect())
+					}
+					if arg.Value < 0 {
+						return &Integer{Value: -arg.Value}
+					}
+					return arg
+				case *Float:
+					return &Float{Value: math.Abs(arg.Value)}
+				default:
+					return newError("argument to `abs` must be a number, got %s", arg.Type())
+				}
+			},
+		},
+	},
+	{
+		"min",
+		&BuiltIn{
+			Signature: "min(x, ...)",
+			Doc:       "Smallest of the arguments",
+			MinArgs:   1,
+			MaxArgs:   -1,
+			Function: func(args ...Object) Object {
+				return extremum("min", args, func(a, b float64) bool { return a < b })
+			},
+		},
+	},
+	{
+		"max",
+		&BuiltIn{
+			Signature: "max(x, ...)",
+			Doc:       "Largest of the arguments",
+			MinArgs:   1,
+			MaxArgs:   -1,
+			Function: func(args ...Object) Object {
+				return extremum("max", args, func(a, b float64) bool { return a > b })
+			},
+		},
+	},
+	{
+		"readLines",
+		&BuiltIn{
+			Signature: "readLines(path)",
+			Doc:       "Lines of a file, without newline characters",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				content, err := readFile("readLines", args[0])
+				if err != nil {
+					return err
+				}
+
+				if content == "" {
+					return &Array{Elements: []Object{}}
+				}
+
+				// A trailing newline ends the last line rather than starting an empty one
+				lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+				elements := make([]Object, len(lines))
+				for i, line := range lines {
+					elements[i] = &String{Value: strings.TrimSuffix(line, "\r")}
+				}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		"type",
+		&BuiltIn{
+			Signature: "type(x)",
+			Doc:       "Type name of x, e.g. \"INTEGER\"",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				// User functions have the same type in both engines
+				switch args[0].Type() {
+				case COMPILED_FUNCTION_OBJECT, CLOSURE_OBJECT:
+					return &String{Value: FUNCTION_OBJECT}
+				default:
+					return &String{Value: string(args[0].Type())}
+				}
+			},
+		},
+	},
+	{
+		"int",
+		&BuiltIn{
+			Signature: "int(x)",
+			Doc:       "Integer parsed from a string, or a float truncated towards zero",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				switch arg := args[0].(type) {
+				case *Integer:
+					return arg
+				case *Float:
+					truncated := math.Trunc(arg.Value)
+					if math.IsNaN(truncated) || truncated < math.MinInt64 || truncated >= math.MaxInt64 {
+						return newError("argument to `int` out of integer range: %s", arg.Inspect())
+					}
+					return &Integer{Value: int64(truncated)}
+				case *String:
+					value, err := strconv.ParseInt(arg.Value, 10, 64)
+					if err != nil {
+						return newError("could not parse %q as integer", arg.Value)
+					}
+					return &Integer{Value: value}
+				default:
+					return newError("argument to `int` must be string or number, got %s", arg.Type())
+				}
+			},
+		},
+	},
+	{
+		"str",
+		&BuiltIn{
+			Signature: "str(x)",
+			Doc:       "Printable string form of x",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				if s, ok := args[0].(*String); ok {
+					return s
+				}
+				return &String{Value: args[0].Inspect()}
+			},
+		},
+	},
+	{
+		"apply",
+		&BuiltIn{
+			Signature: "apply(f, array)",
+			Doc:       "Call f with the elements of array as arguments",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				if !isFunction(args[0]) {
+					return newError("first argument to `apply` must be a function, got %s", args[0].Type())
+				}
+
+				array, ok := args[1].(*Array)
+				if !ok {
+					return newError("second argument to `apply` must be array, got %s", args[1].Type())
+				}
+
+				// Functions can only be called by the engine, so it carries out the call
+				arguments := make([]Object, len(array.Elements))
+				copy(arguments, array.Elements)
+				return &Apply{Function: args[0], Arguments: arguments}
+			},
+		},
+	},
+	{
+		"bind",
+		&BuiltIn{
+			Signature: "bind(f, x)",
+			Doc:       "Function calling f with x as its first argument, followed by its own arguments",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Function: func(args ...Object) Object {
+				f, value := args[0], args[1]
+				if !isFunction(f) {
+					return newError("first argument to `bind` must be a function, got %s", f.Type())
+				}
+
+				return &BuiltIn{
+					Name:      "bound",
+					Signature: "bound(...)",
+					Doc:       "Function returned by bind",
+					MinArgs:   0,
+					MaxArgs:   -1,
+					Function: func(rest ...Object) Object {
+						arguments := append([]Object{value}, rest...)
+						return &Apply{Function: f, Arguments: arguments}
+					},
+				}
+			},
+		},
+	},
+	{
+		"memoize",
+		&BuiltIn{
+			Signature: "memoize(f)",
+			Doc:       "Function caching the results of f by its (hashable) arguments",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				f := args[0]
+				if !isFunction(f) {
+					return newError("argument to `memoize` must be a function, got %s", f.Type())
+				}
+
+				var mutex sync.Mutex
+				cache := map[string]Object{}
+
+				return &BuiltIn{
+					Name:      "memoized",
+					Signature: "memoized(...)",
+					Doc:       "Function returned by memoize",
+					MinArgs:   0,
+					MaxArgs:   -1,
+					Function: func(args ...Object) Object {
+						arguments := make([]Object, len(args))
+						copy(arguments, args)
+
+						// Arguments that can't be hash keys are passed on, without caching the result
+						key, ok := argumentsKey(arguments)
+						if !ok {
+							return &Apply{Function: f, Arguments: arguments}
+						}
+
+						mutex.Lock()
+						result, cached := cache[key]
+						mutex.Unlock()
+						if cached {
+							return result
+						}
+
+						return &Apply{Function: f, Arguments: arguments, Then: func(result Object) Object {
+							if result.Type() != ERROR_OBJECT && result.Type() != PANIC_OBJECT {
+								mutex.Lock()
+								cache[key] = result
+								mutex.Unlock()
+							}
+							return result
+						}}
+					},
+				}
+			},
+		},
+	},
+}
+
+// Helper function for memoize: a key for a list of arguments (false if any of them isn't hashable)
+func argumentsKey(args []Object) (string, bool) {
+	var key strings.Builder
+	for _, arg := range args {
+		hashable, ok := arg.(Hashable)
+		if !ok {
+			return "", false
+		}
+
+		hashKey := hashable.HashKey()
+		fmt.Fprintf(&key, "%s:%d:%q;", hashKey.Type, hashKey.Value, hashKey.str)
+	}
+	return key.String(), true
+}
+
+// Helper function for builtins taking a function (user-defined, compiled or builtin)
+func isFunction(obj Object) bool {
+	switch obj.Type() {
+	case FUNCTION_OBJECT, COMPILED_FUNCTION_OBJECT, CLOSURE_OBJECT, BUILTIN_OBJECT:
+		return true
+	default:
+		return false
+	}
+}
+
+// Helper function for builtins reading a file: fails in sandbox mode
+func readFile(name string, path Object) (string, *Error) {
+	if Sandbox {
+		return "", newError("`%s` is not allowed in sandbox mode", name)
+	}
+
+	p, ok := path.(*String)
+	if !ok {
+		return "", newError("argument to `%s` must be string, got %s", name, path.Type())
+	}
+
+	content, err := os.ReadFile(p.Value)
+	if err != nil {
+		return "", newError("could not read file: %s", err)
+	}
+	return string(content), nil
+}
+
+// Helper function for min/max: the first argument that no later argument is better than
+func extremum(name string, args []Object, better func(float64, float64) bool) Object {
+	var best Object
+
+	for _, arg := range args {
+		if arg.Type() != INTEGER_OBJECT && arg.Type() != FLOAT_OBJECT {
+			return newError("arguments to `%s` must be numbers, got %s", name, arg.Type())
+		}
+
+		if best == nil {
+			best = arg
+			continue
+		}
+
+		// Integers are compared exactly, not as floats
+		a, aIsInteger := arg.(*Integer)
+		b, bIsInteger := best.(*Integer)
+		if aIsInteger && bIsInteger {
+			if better(float64(compareIntegers(a.Value, b.Value)), 0) {
+				best = arg
+			}
+		} else if better(ToFloat(arg), ToFloat(best)) {
+			best = arg
+		}
+	}
+
+	return best
+}
+
+// Helper function: -1, 0 or 1 if a is less than, equal to or greater than b
+func compareIntegers(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Helper function for format and printf: replace each verb in args[0] with the next argument
+// %s is any value, %d an integer and %% a literal %
+func formatString(name string, args []Object) (string, *Error) {
+	template, ok := args[0].(*String)
+	if !ok {
+		return "", newError("first argument to `%s` must be string, got %s", name, args[0].Type())
+	}
+
+	var out strings.Builder
+	values := args[1:]
+	characters := []rune(template.Value)
+
+	for i := 0; i < len(characters); i++ {
+		if characters[i] != '%' {
+			out.WriteRune(characters[i])
+			continue
+		}
+
+		i++
+		if i == len(characters) {
+			return "", newError("format string passed to `%s` ends with %%", name)
+		}
+		verb := characters[i]
+		if verb == '%' {
+			out.WriteRune('%')
+			continue
+		}
+
+		if len(values) == 0 {
+			return "", newError("not enough arguments to `%s` for format string", name)
+		}
+		value := values[0]
+		values = values[1:]
+
+		switch verb {
+		case 's':
+			out.WriteString(value.Inspect())
+		case 'd':
+			integer, ok := value.(*Integer)
+			if !ok {
+				return "", newError("%%d in format string passed to `%s` needs integer, got %s", name, value.Type())
+			}
+			out.WriteString(integer.Inspect())
+		default:
+			return "", newError("unknown verb %%%c in format string passed to `%s`", verb, name)
+		}
+	}
+
+	if len(values) > 0 {
+		return "", newError("too many arguments to `%s` for format string", name)
+	}
+	return out.String(), nil
+}
+
+// Helper function for slice: index counting from the end if negative, clamped to [0, length]
+func clampIndex(index int64, length int64) int64 {
+	if index < 0 {
+		index += length
+	}
+
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// Helper function to get the pairs of a hash in a stable order (see lessKey)
+func sortedPairs(hash *Hash) []HashPair {
+	pairs := []HashPair{}
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return lessKey(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+// Helper function to order hash keys: numbers (by value), then booleans (false first), then strings
+// An integer and a float with the same value (e.g. 1 and 1.0) are ordered integer first
+func lessKey(a, b Object) bool {
+	rankA, rankB := keyRank(a), keyRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	}
+
+	if rankA == 0 {
+		if ToFloat(a) != ToFloat(b) {
+			return ToFloat(a) < ToFloat(b)
+		}
+		return a.Type() == INTEGER_OBJECT && b.Type() == FLOAT_OBJECT
+	}
+	return a.Inspect() < b.Inspect()
+}
+
+// Helper function for lessKey: which group of keys obj sorts in
+func keyRank(obj Object) int {
+	switch obj.(type) {
+	case *Integer, *Float:
+		return 0
+	case *Boolean:
+		return 1
+	case *String:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Helper function for floor/ceil/round/trunc: always returns an integer (integers are passed through)
+func roundToInteger(name string, arg Object, round func(float64) float64) Object {
+	switch arg := arg.(type) {
+	case *Integer:
+		return arg
+	case *Float:
+		value := round(arg.Value)
+
+		// NaN, infinities and floats too large for an integer
+		if math.IsNaN(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+			return newError("argument to `%s` out of integer range: %s", name, arg.Inspect())
+		}
+
+		return &Integer{Value: int64(value)}
+	default:
+		return newError("argument to `%s` must be a number, got %s", name, arg.Type())
+	}
+}
+
+// Helper function for reference identity
+// Numbers and strings aren't interned, so equal values count as the same object
+// (for floats that's ==, as for hash keys: -0.0 is the same as 0.0 and NaN is never the same as anything)
+// Booleans and null are singletons, so pointer comparison already collapses them
+func same(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		other, ok := b.(*Integer)
+		return ok && a.Value == other.Value
+	case *Float:
+		other, ok := b.(*Float)
+		return ok && a.Value == other.Value
+	case *String:
+		other, ok := b.(*String)
+		return ok && a.Value == other.Value
+	default:
+		return a == b
+	}
+}
+
+// Helper function to get the two string arguments of a builtin (arity is already checked)
+func getStringArguments(name string, args []Object) (string, string, *Error) {
+	first, ok := args[0].(*String)
+	if !ok {
+		return "", "", newError("arguments to `%s` must be strings", name)
+	}
+
+	second, ok := args[1].(*String)
+	if !ok {
+		return "", "", newError("arguments to `%s` must be strings", name)
+	}
+
+	return first.Value, second.Value, nil
+}
+
+// Compiled regular expressions, keyed by pattern
+var regexCache = struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}{patterns: make(map[string]*regexp.Regexp)}
+
+// Helper function to compile a regular expression (cached)
+func compileRegex(pattern string) (*regexp.Regexp, *Error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+
+	re, ok := regexCache.patterns[pattern]
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, newError("invalid regular expression %q: %s", pattern, err)
+	}
+
+	regexCache.patterns[pattern] = re
+	return re, nil
+}
+
+// Helper function to convert bool to boolean objects
+func toBoolean(input bool) *Boolean {
+	if input {
+		return TRUE
+	} else {
+		return FALSE
+	}
+}
+
+// Helper function to build padding for padStart/padEnd (string, width, fill character; arity is already checked)
+func getPadding(name string, args []Object) (string, *Error) {
+	str, ok := args[0].(*String)
+	if !ok {
+		return "", newError("first argument to `%s` must be string", name)
+	}
+
+	width, ok := args[1].(*Integer)
+	if !ok {
+		return "", newError("second argument to `%s` must be integer", name)
+	}
+
+	fill, ok := args[2].(*String)
+	if !ok || utf8.RuneCountInString(fill.Value) != 1 {
+		return "", newError("third argument to `%s` must be single character string", name)
+	}
+
+	// Strings already wide enough aren't truncated
+	length := int64(utf8.RuneCountInString(str.Value))
+	if length >= width.Value {
+		return "", nil
+	}
+	if RepeatTooLarge(len(fill.Value), width.Value-length) {
+		return "", newError("second argument to `%s` too large: %d", name, width.Value)
+	}
+
+	return strings.Repeat(fill.Value, int(width.Value-length)), nil
 }
 
 func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
+func init() {
+	// Added here since help looks up Builtins
+	Builtins = append(Builtins, struct {
+		Name    string
+		Builtin *BuiltIn
+	}{
+		"help",
+		&BuiltIn{
+			Signature: "help(name)",
+			Doc:       "Signature and description of a builtin",
+			MinArgs:   1,
+			MaxArgs:   1,
+			Function: func(args ...Object) Object {
+				name, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `help` must be string")
+				}
+
+				builtin := GetBuiltin(name.Value)
+				if builtin == nil {
+					return newError("no builtin named `%s`", name.Value)
+				}
+
+				return &String{Value: builtin.Signature + ": " + builtin.Doc}
+			},
+		},
+	})
+
+	for _, def := range Builtins {
+		def.Builtin.Name = def.Name
+	}
+}
+
 func GetBuiltin(name string) *BuiltIn {
 	for _, def := range Builtins {
 		if def.Name == name {
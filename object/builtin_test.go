@@ -0,0 +1,182 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinArity(t *testing.T) {
+	one := &Integer{Value: 1}
+	str := &String{Value: "a"}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"len", []Object{}, "wrong number of arguments (expected = 1)"},
+		{"len", []Object{str, str}, "wrong number of arguments (expected = 1)"},
+		{"push", []Object{one}, "wrong number of arguments (expected = 2)"},
+		{"padStart", []Object{str, one}, "wrong number of arguments (expected = 3)"},
+		{"replaceRegex", []Object{str, str}, "wrong number of arguments (expected = 3)"},
+	}
+
+	for _, test := range tests {
+		result := GetBuiltin(test.name).Call(test.args...)
+		errObj, ok := result.(*Error)
+		if !ok {
+			t.Fatalf("Expected error calling %s, actual: %T", test.name, result)
+		}
+		assert.Equal(t, test.expected, errObj.Message, test.name)
+	}
+
+	// Every builtin declares a valid arity
+	for _, def := range Builtins {
+		assert.True(t, def.Builtin.MinArgs >= 0, def.Name)
+		assert.True(t, def.Builtin.MaxArgs < 0 || def.Builtin.MaxArgs >= def.Builtin.MinArgs, def.Name)
+	}
+
+	// Variadic and ranged builtins
+	variadic := &BuiltIn{Function: func(args ...Object) Object { return NULL }, MinArgs: 1, MaxArgs: -1}
+	assert.Equal(t, "ERROR: wrong number of arguments (expected >= 1)", variadic.Call().Inspect())
+	assert.Equal(t, NULL, variadic.Call(one, one, one))
+
+	ranged := &BuiltIn{Function: func(args ...Object) Object { return NULL }, MinArgs: 1, MaxArgs: 2}
+	assert.Equal(t, "ERROR: wrong number of arguments (expected 1 to 2)", ranged.Call().Inspect())
+	assert.Equal(t, NULL, ranged.Call(one, one))
+}
+
+func TestHelp(t *testing.T) {
+	help := GetBuiltin("help")
+
+	result := help.Call(&String{Value: "push"})
+	str, ok := result.(*String)
+	if !ok {
+		t.Fatalf("Expected string, actual: %T", result)
+	}
+	assert.Equal(t, "push(array, x): New array with x added to the end", str.Value)
+
+	assert.Equal(t, "ERROR: no builtin named `nope`", help.Call(&String{Value: "nope"}).Inspect())
+
+	// Every builtin is documented
+	for _, def := range Builtins {
+		assert.Equal(t, def.Name, def.Builtin.Name)
+		assert.NotEmpty(t, def.Builtin.Signature, def.Name)
+		assert.NotEmpty(t, def.Builtin.Doc, def.Name)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	str := func(s string) Object { return &String{Value: s} }
+	one := &Integer{Value: 1}
+
+	tests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{str("plain")}, "plain"},
+		{[]Object{str("%s + %d = %s"), one, one, &Integer{Value: 2}}, "1 + 1 = 2"},
+		{[]Object{str("[%s]"), &Array{Elements: []Object{one, str("a")}}}, "[[1, a]]"},
+		{[]Object{str("100%% %s"), TRUE}, "100% true"},
+		{[]Object{str("λ%sλ"), str("é")}, "λéλ"},
+		{[]Object{one}, "ERROR: first argument to `format` must be string, got INTEGER"},
+		{[]Object{str("%s %s"), one}, "ERROR: not enough arguments to `format` for format string"},
+		{[]Object{str("%s"), one, one}, "ERROR: too many arguments to `format` for format string"},
+		{[]Object{str("%d"), str("1")}, "ERROR: %d in format string passed to `format` needs integer, got STRING"},
+		{[]Object{str("%x"), one}, "ERROR: unknown verb %x in format string passed to `format`"},
+		{[]Object{str("50%")}, "ERROR: format string passed to `format` ends with %"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, GetBuiltin("format").Call(test.args...).Inspect(), test.expected)
+	}
+}
+
+func TestPrintfPrintln(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	GetBuiltin("printf").Call(&String{Value: "%s=%d"}, &String{Value: "x"}, &Integer{Value: 1})
+	GetBuiltin("printf").Call(&String{Value: ";"})
+	GetBuiltin("println").Call(&String{Value: "a"}, &Integer{Value: 2}, NULL)
+	GetBuiltin("println").Call()
+	assert.Equal(t, "x=1;a 2 null\n\n", out.String())
+
+	// Nothing is printed if the format string doesn't match the arguments
+	out.Reset()
+	result := GetBuiltin("printf").Call(&String{Value: "%s"})
+	assert.Equal(t, "ERROR: not enough arguments to `printf` for format string", result.Inspect())
+	assert.Equal(t, "", out.String())
+}
+
+func TestReadLines(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content  string
+		expected []string
+	}{
+		{"first\nsecond\n\nfourth\n", []string{"first", "second", "", "fourth"}},
+		{"first\nsecond\n\nfourth", []string{"first", "second", "", "fourth"}},
+		{"windows\r\nline\r\n", []string{"windows", "line"}},
+		{"\n", []string{""}},
+		{"", []string{}},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("lines%d.txt", i))
+		assert.NoError(t, os.WriteFile(path, []byte(test.content), 0644))
+
+		result, ok := GetBuiltin("readLines").Call(&String{Value: path}).(*Array)
+		if !ok {
+			t.Fatalf("Expected array reading %q", test.content)
+		}
+
+		lines := []string{}
+		for _, element := range result.Elements {
+			lines = append(lines, element.(*String).Value)
+		}
+		assert.Equal(t, test.expected, lines, test.content)
+	}
+
+	result := GetBuiltin("readLines").Call(&String{Value: filepath.Join(dir, "missing.txt")})
+	assert.Contains(t, result.Inspect(), "ERROR: could not read file: ")
+
+	result = GetBuiltin("readLines").Call(&Integer{Value: 1})
+	assert.Equal(t, "ERROR: argument to `readLines` must be string, got INTEGER", result.Inspect())
+
+	Sandbox = true
+	defer func() { Sandbox = false }()
+	result = GetBuiltin("readLines").Call(&String{Value: filepath.Join(dir, "lines0.txt")})
+	assert.Equal(t, "ERROR: `readLines` is not allowed in sandbox mode", result.Inspect())
+}
+
+func TestMemoizeCache(t *testing.T) {
+	memoized := GetBuiltin("memoize").Call(GetBuiltin("len")).(*BuiltIn)
+	arg := &String{Value: "abc"}
+
+	// Errors aren't cached, so the call is requested again
+	apply, ok := memoized.Call(arg).(*Apply)
+	if !ok {
+		t.Fatalf("Expected call to be requested")
+	}
+	apply.Then(newError("failed"))
+
+	apply, ok = memoized.Call(arg).(*Apply)
+	if !ok {
+		t.Fatalf("Expected call to be requested again after an error")
+	}
+	assert.Equal(t, "3", apply.Then(&Integer{Value: 3}).Inspect())
+
+	// Same arguments give the cached result
+	assert.Equal(t, "3", memoized.Call(&String{Value: "abc"}).Inspect())
+
+	// Unhashable arguments are never cached
+	apply, ok = memoized.Call(&Array{}).(*Apply)
+	assert.True(t, ok)
+	assert.Nil(t, apply.Then)
+}
@@ -1,8 +1,25 @@
 package object
 
+import "go_interpreter/ast"
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store        map[string]Object
+	outer        *Environment
+	function     bool                     // Environment of a function call
+	deferred     []Deferred               // Expressions to run when function returns
+	panic        *Panic                   // Panic being unwound while deferred expressions run
+	deferring    *Environment             // Function environment that deferred this call (its panic can be recovered here)
+	meta         map[string]Object        // Host data, not visible to scripts through Get/Set
+	consts       map[string]bool          // Names bound with "const" in this environment
+	declarations map[string]ast.Statement // Statement that last bound each name in this environment
+	strict       bool                     // Redeclaring a name in the same environment is an error
+	blocks       bool                     // If and while bodies get their own environment
+}
+
+// Expression scheduled by "defer", with the environment it was deferred in
+type Deferred struct {
+	Expression ast.Expression
+	Env        *Environment
 }
 
 func BuildEnvironment() *Environment {
@@ -13,6 +30,14 @@ func BuildEnvironment() *Environment {
 func BuildInnerEnvironment(outer *Environment) *Environment {
 	env := BuildEnvironment()
 	env.outer = outer
+	env.strict = outer.strict
+	env.blocks = outer.blocks
+	return env
+}
+
+func BuildFunctionEnvironment(outer *Environment) *Environment {
+	env := BuildInnerEnvironment(outer)
+	env.function = true
 	return env
 }
 
@@ -28,3 +53,140 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Bind a name that can't be reassigned in this environment
+func (e *Environment) SetConst(name string, val Object) Object {
+	if e.consts == nil {
+		e.consts = make(map[string]bool)
+	}
+
+	e.consts[name] = true
+	return e.Set(name, val)
+}
+
+// Check if name was bound with SetConst in this environment (inner environments may shadow it)
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// Record that statement bound name in this environment
+func (e *Environment) Declare(name string, statement ast.Statement) {
+	if e.declarations == nil {
+		e.declarations = make(map[string]ast.Statement)
+	}
+
+	e.declarations[name] = statement
+}
+
+// Check if name is bound in this environment by something other than statement
+// Running the same statement again (e.g. in a loop body) isn't a redeclaration
+func (e *Environment) IsRedeclared(name string, statement ast.Statement) bool {
+	return e.IsDeclared(name) && e.declarations[name] != statement
+}
+
+// Turn strict mode on or off (inner environments built afterwards inherit it)
+func (e *Environment) SetStrict(strict bool) {
+	e.strict = strict
+}
+
+func (e *Environment) Strict() bool {
+	return e.strict
+}
+
+// Turn block scoping on or off (inner environments built afterwards inherit it)
+// With block scoping, a let inside an if or while body isn't visible after the body
+func (e *Environment) SetBlockScoped(blocks bool) {
+	e.blocks = blocks
+}
+
+func (e *Environment) BlockScoped() bool {
+	return e.blocks
+}
+
+// Check if name is bound in this environment (not an outer one)
+func (e *Environment) IsDeclared(name string) bool {
+	_, ok := e.store[name]
+	return ok
+}
+
+// Copies of the bindings in this environment and each outer one, innermost first (for debugging)
+func (e *Environment) Chain() []map[string]Object {
+	chain := []map[string]Object{}
+	for env := e; env != nil; env = env.outer {
+		bindings := make(map[string]Object, len(env.store))
+		for name, val := range env.store {
+			bindings[name] = val
+		}
+		chain = append(chain, bindings)
+	}
+	return chain
+}
+
+// Store host data (e.g. for embedders) that scripts can't see
+func (e *Environment) SetMeta(key string, val Object) Object {
+	if e.meta == nil {
+		e.meta = make(map[string]Object)
+	}
+
+	e.meta[key] = val
+	return val
+}
+
+// Look up host data stored with SetMeta, here or in an outer environment
+func (e *Environment) GetMeta(key string) (Object, bool) {
+	val, ok := e.meta[key]
+	if !ok && e.outer != nil {
+		val, ok = e.outer.GetMeta(key)
+	}
+	return val, ok
+}
+
+// Schedule expression on the nearest function environment (false if there is none)
+func (e *Environment) Defer(expression ast.Expression) bool {
+	for f := e; f != nil; f = f.outer {
+		if f.function {
+			f.deferred = append(f.deferred, Deferred{expression, e})
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Environment) SetPanic(p *Panic) {
+	e.panic = p
+}
+
+func (e *Environment) Panic() *Panic {
+	return e.panic
+}
+
+// Mark this as the environment of a call deferred by env, so it can recover env's panic
+func (e *Environment) SetDeferring(env *Environment) {
+	e.deferring = env
+}
+
+// Stop the nearest panic being unwound and return its value (nil if there is none)
+// The panic is looked for in enclosing environments and in the function that deferred the call
+func (e *Environment) Recover() Object {
+	for f := e; f != nil; f = f.outer {
+		for _, p := range []*Environment{f, f.deferring} {
+			if p != nil && p.panic != nil {
+				value := p.panic.Value
+				p.panic = nil
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+// Remove and return deferred expressions in the order they should run (last in, first out)
+func (e *Environment) TakeDeferred() []Deferred {
+	deferred := make([]Deferred, len(e.deferred))
+	for i, d := range e.deferred {
+		deferred[len(e.deferred)-1-i] = d
+	}
+
+	e.deferred = nil
+	return deferred
+}
@@ -0,0 +1,92 @@
+package object
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConst(t *testing.T) {
+	env := BuildEnvironment()
+	env.SetConst("x", &Integer{Value: 1})
+	env.Set("y", &Integer{Value: 2})
+
+	x, ok := env.Get("x")
+	assert.True(t, ok)
+	assert.Equal(t, "1", x.Inspect())
+	assert.True(t, env.IsConst("x"))
+	assert.False(t, env.IsConst("y"))
+
+	// Inner environments can shadow constants
+	inner := BuildInnerEnvironment(env)
+	assert.False(t, inner.IsConst("x"))
+}
+
+func TestStrict(t *testing.T) {
+	env := BuildEnvironment()
+	assert.False(t, env.Strict())
+
+	env.SetStrict(true)
+	env.Set("x", &Integer{Value: 1})
+	assert.True(t, env.IsDeclared("x"))
+	assert.False(t, env.IsDeclared("y"))
+
+	// Inner environments inherit strict mode, but not declarations
+	inner := BuildInnerEnvironment(env)
+	assert.True(t, inner.Strict())
+	assert.False(t, inner.IsDeclared("x"))
+}
+
+func TestBlockScoped(t *testing.T) {
+	env := BuildEnvironment()
+	assert.False(t, env.BlockScoped())
+
+	env.SetBlockScoped(true)
+	assert.True(t, BuildInnerEnvironment(env).BlockScoped())
+	assert.True(t, BuildFunctionEnvironment(env).BlockScoped())
+}
+
+func TestChain(t *testing.T) {
+	global := BuildEnvironment()
+	global.Set("x", &Integer{Value: 1})
+	global.Set("y", &Integer{Value: 2})
+	function := BuildFunctionEnvironment(global)
+	function.Set("x", &Integer{Value: 3})
+	block := BuildInnerEnvironment(function)
+
+	chain := block.Chain()
+	assert.Len(t, chain, 3)
+	assert.Empty(t, chain[0])
+	assert.Len(t, chain[1], 1)
+	assert.Equal(t, "3", chain[1]["x"].Inspect())
+	assert.Len(t, chain[2], 2)
+	assert.Equal(t, "1", chain[2]["x"].Inspect())
+	assert.Equal(t, "2", chain[2]["y"].Inspect())
+
+	// The chain is a copy
+	chain[2]["z"] = &Integer{Value: 4}
+	_, ok := global.Get("z")
+	assert.False(t, ok)
+}
+
+func TestMeta(t *testing.T) {
+	env := BuildEnvironment()
+	env.SetMeta("x", &Integer{Value: 1})
+
+	// Metadata and bindings are separate
+	_, ok := env.Get("x")
+	assert.False(t, ok, "metadata shouldn't be a binding")
+
+	env.Set("x", &Integer{Value: 2})
+	meta, ok := env.GetMeta("x")
+	assert.True(t, ok)
+	assert.Equal(t, "1", meta.Inspect())
+
+	// Inner environments see outer metadata
+	inner := BuildInnerEnvironment(env)
+	meta, ok = inner.GetMeta("x")
+	assert.True(t, ok)
+	assert.Equal(t, "1", meta.Inspect())
+
+	_, ok = inner.GetMeta("y")
+	assert.False(t, ok)
+}
@@ -6,6 +6,9 @@ import (
 	"go_interpreter/ast"
 	"go_interpreter/bytecode"
 	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -13,22 +16,49 @@ type ObjectType string
 
 const (
 	INTEGER_OBJECT           = "INTEGER"
+	FLOAT_OBJECT             = "FLOAT"
 	BOOLEAN_OBJECT           = "BOOLEAN"
 	NULL_OBJECT              = "NULL"
 	RETURN_OBJECT            = "RETURN"
+	BREAK_OBJECT             = "BREAK"
+	CONTINUE_OBJECT          = "CONTINUE"
 	ERROR_OBJECT             = "ERROR"
+	PANIC_OBJECT             = "PANIC"
+	APPLY_OBJECT             = "APPLY"
 	FUNCTION_OBJECT          = "FUNCTION"
 	COMPILED_FUNCTION_OBJECT = "COMPILED_FUNCTION"
+	CLOSURE_OBJECT           = "CLOSURE"
 	STRING_OBJECT            = "STRING"
 	BUILTIN_OBJECT           = "BUILTIN"
 	ARRAY_OBJECT             = "ARRAY"
 	HASH_OBJECT              = "HASH"
+	QUOTE_OBJECT             = "QUOTE"
+	MACRO_OBJECT             = "MACRO"
 )
 
+// Shared by both engines so values can be compared by pointer
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// Upper limit on number of elements in an array or hash literal (0 is unlimited)
+var MaxLiteralSize = 0
+
+// Upper limit on number of elements (or bytes) a repetition like [0] * n or "a" * n can produce
+const MaxRepeatSize = 1 << 27
+
+// Check if repeating size elements (or bytes) count times would be more than MaxRepeatSize
+func RepeatTooLarge(size int, count int64) bool {
+	return size > 0 && count > int64(MaxRepeatSize/size)
+}
+
 // Generic object
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	Clone() Object // Scalars return themselves, containers are deep-copied
 }
 
 // Integer type
@@ -44,6 +74,32 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+func (i *Integer) Clone() Object {
+	return i
+}
+
+// Float type
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJECT
+}
+
+// Whole numbers keep a ".0" so floats can be told apart from integers
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+	return str
+}
+
+func (f *Float) Clone() Object {
+	return f
+}
+
 // Boolean type
 type Boolean struct {
 	Value bool
@@ -57,6 +113,10 @@ func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
 
+func (b *Boolean) Clone() Object {
+	return b
+}
+
 // Null type
 type Null struct{}
 
@@ -68,6 +128,10 @@ func (n *Null) Inspect() string {
 	return "null"
 }
 
+func (n *Null) Clone() Object {
+	return n
+}
+
 // Return type
 type Return struct {
 	Value Object
@@ -81,9 +145,53 @@ func (r *Return) Inspect() string {
 	return r.Value.Inspect()
 }
 
+func (r *Return) Clone() Object {
+	return &Return{Value: r.Value.Clone()}
+}
+
+// Break type (unwinds blocks up to the nearest loop)
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJECT
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+func (b *Break) Clone() Object {
+	return b
+}
+
+// Continue type (unwinds blocks up to the nearest loop)
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJECT
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+func (c *Continue) Clone() Object {
+	return c
+}
+
 // Error type
 type Error struct {
 	Message string
+	Line    int     // Source line the error happened on (0 if unknown)
+	Column  int     // Source column the error happened on (0 if unknown)
+	Trace   []Frame // Calls the error propagated through, innermost first
+}
+
+// A call the error propagated through, e.g. "fib" called at line 3, column 12
+type Frame struct {
+	Function string
+	Line     int
+	Column   int
 }
 
 func (e *Error) Type() ObjectType {
@@ -94,6 +202,91 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Most trace lines Describe shows before summing up the rest
+const MaxTraceLines = 20
+
+// Like Inspect, with the source position in front of the message if known
+// e.g. "ERROR: line 12, column 5: identifier not found: foo"
+// followed by a line per call in the trace, e.g. "  in f (line 14, column 2)"
+// Inspect stays one line, since an error is also a value (e.g. the result of a REPL line); this is for reporting it
+// A run of more than two calls from the same place (e.g. recursion) is shown once, with "  ... same call N more times"
+func (e *Error) Describe() string {
+	var out bytes.Buffer
+
+	if e.Line == 0 {
+		out.WriteString(e.Inspect())
+	} else {
+		out.WriteString(fmt.Sprintf("ERROR: line %d, column %d: %s", e.Line, e.Column, e.Message))
+	}
+
+	lines := 0
+	for i := 0; i < len(e.Trace); {
+		if lines == MaxTraceLines {
+			out.WriteString(fmt.Sprintf("\n  ... %d more calls", len(e.Trace)-i))
+			break
+		}
+
+		frame := e.Trace[i]
+		repeats := 1
+		for i+repeats < len(e.Trace) && e.Trace[i+repeats] == frame {
+			repeats++
+		}
+		if repeats <= 2 {
+			repeats = 1
+		}
+
+		out.WriteString(fmt.Sprintf("\n  in %s (line %d, column %d)", frame.Function, frame.Line, frame.Column))
+		if repeats > 1 {
+			out.WriteString(fmt.Sprintf("\n  ... same call %d more times", repeats-1))
+		}
+
+		i += repeats
+		lines++
+	}
+
+	return out.String()
+}
+
+func (e *Error) Clone() Object {
+	return e
+}
+
+// Panic type (unwinds calls until recovered in a deferred expression)
+type Panic struct {
+	Value Object
+}
+
+func (p *Panic) Type() ObjectType {
+	return PANIC_OBJECT
+}
+
+func (p *Panic) Inspect() string {
+	return "panic: " + p.Value.Inspect()
+}
+
+func (p *Panic) Clone() Object {
+	return p
+}
+
+// Apply type (call requested by the apply builtin, carried out by the engine running it)
+type Apply struct {
+	Function  Object
+	Arguments []Object
+	Then      func(result Object) Object // If set, gets the result of the call and returns the builtin's result
+}
+
+func (a *Apply) Type() ObjectType {
+	return APPLY_OBJECT
+}
+
+func (a *Apply) Inspect() string {
+	return "apply: " + a.Function.Inspect()
+}
+
+func (a *Apply) Clone() Object {
+	return a
+}
+
 // Function type (represents evaluated function literals)
 type Function struct {
 	Parameters []*ast.Identifier
@@ -123,11 +316,66 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+func (f *Function) Clone() Object {
+	return f
+}
+
+// Quote type (unevaluated code)
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJECT
+}
+
+func (q *Quote) Inspect() string {
+	return q.Node.String()
+}
+
+func (q *Quote) Clone() Object {
+	return q
+}
+
+// Macro type (like a function, but takes and returns quoted code)
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJECT
+}
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+func (m *Macro) Clone() Object {
+	return m
+}
+
 // Function type (holds bytecode instead of nodes)
 type CompiledFunction struct {
 	Instructions  bytecode.Instructions // Instructions for function body
 	NumLocals     int                   // Number of local bindings this function will create
 	NumParameters int                   // Number of parameters of function
+	SourceMap     map[int]int           // Instruction offset --> source line
 }
 
 func (c *CompiledFunction) Type() ObjectType {
@@ -135,7 +383,34 @@ func (c *CompiledFunction) Type() ObjectType {
 }
 
 func (c *CompiledFunction) Inspect() string {
-	return fmt.Sprintf("CompiledFunction[%p]", c)
+	return fmt.Sprintf("CompiledFunction[%d params, %d locals]", c.NumParameters, c.NumLocals)
+}
+
+// Disassembled instructions of the function body
+func (c *CompiledFunction) Disassemble() string {
+	return c.Instructions.String()
+}
+
+func (c *CompiledFunction) Clone() Object {
+	return c
+}
+
+// Closure type (compiled function with the free variables it captured when created)
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType {
+	return CLOSURE_OBJECT
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%d params, %d locals, %d free]", c.Fn.NumParameters, c.Fn.NumLocals, len(c.Free))
+}
+
+func (c *Closure) Clone() Object {
+	return c
 }
 
 // String type
@@ -151,11 +426,50 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
+func (s *String) Clone() Object {
+	return s
+}
+
+// Get the character at index i (null if out of range)
+func (s *String) Index(i int64) Object {
+	characters := []rune(s.Value)
+	if i < 0 || i >= int64(len(characters)) {
+		return NULL
+	}
+	return &String{Value: string(characters[i])}
+}
+
 // Built in function type
 type BuiltInFunction func(args ...Object) Object
 
 type BuiltIn struct {
-	Function BuiltInFunction
+	Function  BuiltInFunction
+	MinArgs   int    // Fewest arguments accepted
+	MaxArgs   int    // Most arguments accepted (-1 for any number)
+	Name      string // Name the builtin is bound to
+	Signature string // e.g. "push(array, x)"
+	Doc       string // One line description
+}
+
+// Call builtin after checking the number of arguments
+func (b *BuiltIn) Call(args ...Object) Object {
+	if len(args) < b.MinArgs || (b.MaxArgs >= 0 && len(args) > b.MaxArgs) {
+		return b.arityError()
+	}
+
+	return b.Function(args...)
+}
+
+// Helper method for reporting a call with the wrong number of arguments
+func (b *BuiltIn) arityError() *Error {
+	switch {
+	case b.MinArgs == b.MaxArgs:
+		return newError("wrong number of arguments (expected = %d)", b.MinArgs)
+	case b.MaxArgs < 0:
+		return newError("wrong number of arguments (expected >= %d)", b.MinArgs)
+	default:
+		return newError("wrong number of arguments (expected %d to %d)", b.MinArgs, b.MaxArgs)
+	}
 }
 
 func (b *BuiltIn) Type() ObjectType {
@@ -166,6 +480,10 @@ func (b *BuiltIn) Inspect() string {
 	return "built in function"
 }
 
+func (b *BuiltIn) Clone() Object {
+	return b
+}
+
 // Array type
 type Array struct {
 	Elements []Object
@@ -176,24 +494,31 @@ func (a *Array) Type() ObjectType {
 }
 
 func (a *Array) Inspect() string {
-	var out bytes.Buffer
-
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
-	}
+	return inspect(a, map[Object]bool{})
+}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
+func (a *Array) Clone() Object {
+	return clone(a, map[Object]Object{})
+}
 
-	return out.String()
+// Get the element at index i, counting back from the end if i is negative (null if out of range)
+func (a *Array) Index(i int64) Object {
+	if i < 0 {
+		i += int64(len(a.Elements))
+	}
+	if i < 0 || i >= int64(len(a.Elements)) {
+		return NULL
+	}
+	return a.Elements[i]
 }
 
 // Hash key type
+// Integers, floats and booleans fit in Value exactly; strings are hashed,
+// so the string is kept too and keys with colliding hashes stay distinct
 type HashKey struct {
 	Type  ObjectType // Type of key
 	Value uint64     // Actual hash
+	str   string     // Original string (string keys only)
 }
 
 func (b *Boolean) HashKey() HashKey {
@@ -212,11 +537,29 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (f *Float) HashKey() HashKey {
+	// -0.0 == 0.0, so they're the same key
+	if f.Value == 0 {
+		return HashKey{Type: f.Type(), Value: 0}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+// Get obj as a hash key (false if it can't be one, e.g. an array, or NaN since NaN != NaN)
+func AsHashKey(obj Object) (Hashable, bool) {
+	if f, ok := obj.(*Float); ok && math.IsNaN(f.Value) {
+		return nil, false
+	}
+
+	hashable, ok := obj.(Hashable)
+	return hashable, ok
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+	return HashKey{Type: s.Type(), Value: h.Sum64(), str: s.Value}
 }
 
 // Hash pair type
@@ -234,16 +577,85 @@ func (h *Hash) Type() ObjectType {
 }
 
 func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+	return inspect(h, map[Object]bool{})
+}
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+func (h *Hash) Clone() Object {
+	return clone(h, map[Object]Object{})
+}
+
+// Helper function for deep-copying arrays and hashes that may contain themselves
+// copies maps containers already copied to their copy, so a cyclic original gives an equally cyclic copy
+func clone(obj Object, copies map[Object]Object) Object {
+	if c, ok := copies[obj]; ok {
+		return c
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		c := &Array{Elements: make([]Object, len(obj.Elements))}
+		copies[obj] = c
+
+		for i, e := range obj.Elements {
+			c.Elements[i] = clone(e, copies)
+		}
+		return c
+	case *Hash:
+		c := &Hash{Pairs: make(map[HashKey]HashPair)}
+		copies[obj] = c
+
+		for k, pair := range obj.Pairs {
+			c.Pairs[k] = HashPair{Key: clone(pair.Key, copies), Value: clone(pair.Value, copies)}
+		}
+		return c
+	default:
+		return obj.Clone()
 	}
+}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+// Helper function for inspecting arrays and hashes that may contain themselves
+// visiting holds the containers currently being printed: reaching one again prints [...] or {...}
+func inspect(obj Object, visiting map[Object]bool) string {
+	var out bytes.Buffer
+
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, visiting))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, visiting), inspect(pair.Value, visiting)))
+		}
+
+		// Sort so output doesn't depend on map iteration order
+		sort.Strings(pairs)
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
+	}
 
 	return out.String()
 }
@@ -252,3 +664,186 @@ func (h *Hash) Inspect() string {
 type Hashable interface {
 	HashKey() HashKey
 }
+
+// Check if two objects have the same value (arrays and hashes are compared element by element)
+// Functions are compared by identity: a function value only equals itself
+func Equals(a Object, b Object) bool {
+	return equals(a, b, map[[2]Object]bool{})
+}
+
+// Helper function for comparing arrays and hashes that may contain themselves
+// comparing holds pairs already being compared: meeting one again adds no new difference, so it counts as equal
+func equals(a Object, b Object, comparing map[[2]Object]bool) bool {
+	// Numbers compare like ==, so 1 equals 1.0 and NaN doesn't equal itself
+	if IsFloatOperation(a, b) {
+		return ToFloat(a) == ToFloat(b)
+	}
+
+	if a == b {
+		return true
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	pair := [2]Object{a, b}
+	if comparing[pair] {
+		return true
+	}
+	comparing[pair] = true
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Float:
+		return a.Value == b.(*Float).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Array:
+		elements := b.(*Array).Elements
+		if len(a.Elements) != len(elements) {
+			return false
+		}
+
+		for i, e := range a.Elements {
+			if !equals(e, elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		pairs := b.(*Hash).Pairs
+		if len(a.Pairs) != len(pairs) {
+			return false
+		}
+
+		for k, pair := range a.Pairs {
+			other, ok := pairs[k]
+			if !ok || !equals(pair.Value, other.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	case *Function, *CompiledFunction, *Closure, *BuiltIn, *Macro:
+		// Functions are only equal to themselves (checked above), never to another literal with the same body
+		return false
+	default:
+		return false
+	}
+}
+
+// Check if item is an element of an array, a key of a hash or a substring of a string
+// Second result is false if item can't be looked up in container
+func Contains(container Object, item Object) (bool, bool) {
+	switch container := container.(type) {
+	case *Array:
+		for _, e := range container.Elements {
+			if Equals(e, item) {
+				return true, true
+			}
+		}
+		return false, true
+	case *Hash:
+		key, ok := AsHashKey(item)
+		if !ok {
+			return false, false
+		}
+
+		_, found := container.Pairs[key.HashKey()]
+		return found, true
+	case *String:
+		sub, ok := item.(*String)
+		if !ok {
+			return false, false
+		}
+
+		return strings.Contains(container.Value, sub.Value), true
+	default:
+		return false, false
+	}
+}
+
+// Convert an object to a Go value for embedders
+// Integers become int, floats float64, booleans bool, strings string and null nil
+// Arrays become []interface{} and hashes map[interface{}]interface{}
+func ToGo(obj Object) (interface{}, error) {
+	return toGo(obj, map[Object]bool{})
+}
+
+// Helper function for converting arrays and hashes that may contain themselves
+// visiting holds the containers currently being converted: reaching one again is an error
+func toGo(obj Object, visiting map[Object]bool) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return int(obj.Value), nil
+	case *Float:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		if visiting[obj] {
+			return nil, fmt.Errorf("cannot convert ARRAY that contains itself")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		elements := make([]interface{}, len(obj.Elements))
+		for i, e := range obj.Elements {
+			value, err := toGo(e, visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		if visiting[obj] {
+			return nil, fmt.Errorf("cannot convert HASH that contains itself")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := toGo(pair.Key, visiting)
+			if err != nil {
+				return nil, err
+			}
+			value, err := toGo(pair.Value, visiting)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key] = value
+		}
+		return pairs, nil
+	case nil:
+		return nil, fmt.Errorf("cannot convert missing object")
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+	}
+}
+
+// Check for float arithmetic: both operands are numbers and at least one is a float
+func IsFloatOperation(left Object, right Object) bool {
+	isNumber := func(obj Object) bool {
+		return obj.Type() == INTEGER_OBJECT || obj.Type() == FLOAT_OBJECT
+	}
+
+	return isNumber(left) && isNumber(right) &&
+		(left.Type() == FLOAT_OBJECT || right.Type() == FLOAT_OBJECT)
+}
+
+// Get a number (integer or float) as a float
+func ToFloat(number Object) float64 {
+	if integer, ok := number.(*Integer); ok {
+		return float64(integer.Value)
+	}
+	return number.(*Float).Value
+}
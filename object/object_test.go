@@ -0,0 +1,209 @@
+package object
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go_interpreter/ast"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestHashInspect(t *testing.T) {
+	pairs := make(map[HashKey]HashPair)
+	for i, k := range []string{"c", "a", "d", "b", "e"} {
+		key := &String{Value: k}
+		pairs[key.HashKey()] = HashPair{Key: key, Value: &Integer{Value: int64(i)}}
+	}
+	hash := &Hash{Pairs: pairs}
+
+	expected := "{a: 1, b: 3, c: 0, d: 2, e: 4}"
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, expected, hash.Inspect())
+	}
+}
+
+func TestDescribeLongTrace(t *testing.T) {
+	err := &Error{Message: "failed", Line: 1, Column: 2}
+	for i := 0; i < MaxTraceLines+5; i++ {
+		// Alternating calls don't collapse
+		err.Trace = append(err.Trace, Frame{Function: "f", Line: 3 + i%2, Column: 1})
+	}
+
+	lines := strings.Split(err.Describe(), "\n")
+	assert.Equal(t, MaxTraceLines+2, len(lines))
+	assert.Equal(t, "ERROR: line 1, column 2: failed", lines[0])
+	assert.Equal(t, "  in f (line 3, column 1)", lines[1])
+	assert.Equal(t, "  ... 5 more calls", lines[len(lines)-1])
+}
+
+func TestFloat(t *testing.T) {
+	assert.Equal(t, "5.5", (&Float{Value: 5.5}).Inspect())
+	assert.Equal(t, "2.0", (&Float{Value: 2}).Inspect())
+	assert.Equal(t, "-0.25", (&Float{Value: -0.25}).Inspect())
+	assert.Equal(t, "1e+21", (&Float{Value: 1e21}).Inspect())
+
+	// Floats and integers are distinct hash keys
+	assert.Equal(t, (&Float{Value: 1.5}).HashKey(), (&Float{Value: 1.5}).HashKey())
+	assert.NotEqual(t, (&Float{Value: 1}).HashKey(), (&Integer{Value: 1}).HashKey())
+
+	// -0.0 == 0.0, so they're the same key
+	assert.Equal(t, (&Float{Value: 0}).HashKey(), (&Float{Value: math.Copysign(0, -1)}).HashKey())
+	_, ok := AsHashKey(&Float{Value: math.NaN()})
+	assert.False(t, ok)
+
+	// Equals agrees with ==, which promotes integers
+	assert.True(t, Equals(&Float{Value: 1.5}, &Float{Value: 1.5}))
+	assert.True(t, Equals(&Float{Value: 1}, &Integer{Value: 1}))
+	assert.False(t, Equals(&Float{Value: math.NaN()}, &Float{Value: math.NaN()}))
+}
+
+func TestHashKeyCollision(t *testing.T) {
+	a := &String{Value: "a"}
+	b := &String{Value: "b"}
+
+	// Simulate an FNV collision between "a" and "b"
+	keyA := a.HashKey()
+	keyB := b.HashKey()
+	keyB.Value = keyA.Value
+
+	pairs := make(map[HashKey]HashPair)
+	pairs[keyA] = HashPair{Key: a, Value: &Integer{Value: 1}}
+	pairs[keyB] = HashPair{Key: b, Value: &Integer{Value: 2}}
+	hash := &Hash{Pairs: pairs}
+
+	assert.Equal(t, 2, len(hash.Pairs))
+	assert.Equal(t, "1", hash.Pairs[keyA].Value.Inspect())
+	assert.Equal(t, "2", hash.Pairs[keyB].Value.Inspect())
+	assert.Equal(t, "{a: 1, b: 2}", hash.Inspect())
+
+	// Equal strings still share a key
+	assert.Equal(t, a.HashKey(), (&String{Value: "a"}).HashKey())
+}
+
+func TestInspectCycle(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+	assert.Equal(t, "[1, [...]]", array.Inspect())
+
+	key := &String{Value: "self"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+	assert.Equal(t, "{self: {...}}", hash.Inspect())
+
+	// Same array twice (but not inside itself) isn't a cycle
+	inner := &Array{Elements: []Object{&Integer{Value: 2}}}
+	outer := &Array{Elements: []Object{inner, inner}}
+	assert.Equal(t, "[[2], [2]]", outer.Inspect())
+
+	// Cycle through a hash
+	wrapper := &Array{}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: wrapper}
+	wrapper.Elements = []Object{hash}
+	assert.Equal(t, "[{self: [...]}]", wrapper.Inspect())
+}
+
+func TestEquals(t *testing.T) {
+	one := &Integer{Value: 1}
+	array := &Array{Elements: []Object{one, &String{Value: "a"}}}
+
+	assert.True(t, Equals(&Integer{Value: 1}, one))
+	assert.True(t, Equals(array, array.Clone()))
+	assert.False(t, Equals(array, &Array{Elements: []Object{one}}))
+	assert.False(t, Equals(one, &String{Value: "1"}))
+	assert.True(t, Equals(NULL, NULL))
+}
+
+func TestFunctionEquals(t *testing.T) {
+	body := &ast.BlockStatement{}
+	f := &Function{Body: body}
+	g := &Function{Body: body}
+	compiled := &CompiledFunction{}
+
+	assert.True(t, Equals(f, f))
+	assert.True(t, Equals(compiled, compiled))
+	assert.True(t, Equals(GetBuiltin("len"), GetBuiltin("len")))
+
+	// Distinct functions are never equal, even with the same body
+	assert.False(t, Equals(f, g))
+	assert.False(t, Equals(compiled, &CompiledFunction{}))
+	assert.False(t, Equals(GetBuiltin("len"), GetBuiltin("first")))
+
+	// Functions are never equal to non-functions
+	assert.False(t, Equals(f, NULL))
+	assert.False(t, Equals(&Integer{Value: 1}, compiled))
+	assert.False(t, Equals(f, compiled))
+}
+
+func TestCyclicCloneEquals(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	clone := array.Clone().(*Array)
+	assert.True(t, clone != array, "clone should be a new array")
+	assert.True(t, clone.Elements[1] == clone, "clone should refer to itself, not the original")
+	assert.Equal(t, "[1, [...]]", clone.Inspect())
+
+	assert.True(t, Equals(array, clone))
+
+	different := &Array{Elements: []Object{&Integer{Value: 2}}}
+	different.Elements = append(different.Elements, different)
+	assert.False(t, Equals(array, different))
+
+	// Hash containing itself
+	key := &String{Value: "self"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+
+	hashClone := hash.Clone().(*Hash)
+	assert.True(t, hashClone.Pairs[key.HashKey()].Value == hashClone)
+	assert.True(t, Equals(hash, hashClone))
+}
+
+func TestCloneNestedArray(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}
+	outer := &Array{Elements: []Object{inner, &String{Value: "foo"}}}
+
+	clone := outer.Clone().(*Array)
+	assert.Equal(t, outer.Inspect(), clone.Inspect())
+
+	// Mutating the original doesn't affect the clone
+	inner.Elements[0] = &Integer{Value: 99}
+	outer.Elements[1] = &String{Value: "bar"}
+
+	assert.Equal(t, "[[99, 2], bar]", outer.Inspect())
+	assert.Equal(t, "[[1, 2], foo]", clone.Inspect())
+}
+
+func TestToGo(t *testing.T) {
+	key := &String{Value: "a"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		key.HashKey(): {Key: key, Value: &Array{Elements: []Object{&Integer{Value: 1}, &Float{Value: 2.5}, NULL}}},
+	}}
+
+	tests := []struct {
+		input    Object
+		expected interface{}
+	}{
+		{&Integer{Value: 5}, 5},
+		{&Float{Value: 1.5}, 1.5},
+		{TRUE, true},
+		{&String{Value: "foo"}, "foo"},
+		{NULL, nil},
+		{hash, map[interface{}]interface{}{"a": []interface{}{1, 2.5, nil}}},
+	}
+
+	for _, test := range tests {
+		value, err := ToGo(test.input)
+		assert.NoError(t, err, test.input.Inspect())
+		assert.Equal(t, test.expected, value, test.input.Inspect())
+	}
+
+	// Arrays that contain themselves and functions can't be converted
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+	_, err := ToGo(array)
+	assert.EqualError(t, err, "cannot convert ARRAY that contains itself")
+
+	_, err = ToGo(&BuiltIn{Name: "len"})
+	assert.EqualError(t, err, "cannot convert BUILTIN to a Go value")
+}
@@ -20,13 +20,31 @@ type Parser struct {
 
 	errors []string // errors when parsing
 
-	prefixMap map[token.TokenType]parsePrefix // parse prefix expressions
-	infixMap  map[token.TokenType]parseInfix  // parse infix expressions
+	prefixMap   map[token.TokenType]parsePrefix // parse prefix expressions
+	infixMap    map[token.TokenType]parseInfix  // parse infix expressions
+	precedences map[token.TokenType]int         // precedences of infix operators
+}
+
+// Settings for a parser (zero values use the defaults)
+type Options struct {
+	Precedences map[token.TokenType]int // Precedences replacing (or added to) the default ones, e.g. {token.PLUS: PRODUCT}
 }
 
 func BuildParser(l *lexer.Lexer) *Parser {
+	return BuildParserWithOptions(l, Options{})
+}
+
+func BuildParserWithOptions(l *lexer.Lexer, options Options) *Parser {
 	p := &Parser{l: l, errors: []string{}}
 
+	p.precedences = make(map[token.TokenType]int)
+	for t, precedence := range precedencesMap {
+		p.precedences[t] = precedence
+	}
+	for t, precedence := range options.Precedences {
+		p.precedences[t] = precedence
+	}
+
 	// Set currentToken and nextToken
 	p.GetNextToken()
 	p.GetNextToken()
@@ -35,16 +53,20 @@ func BuildParser(l *lexer.Lexer) *Parser {
 	p.prefixMap = make(map[token.TokenType]parsePrefix)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefix)
 	p.registerPrefix(token.MINUS, p.parsePrefix)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGrouped)
 	p.registerPrefix(token.IF, p.parseIf)
+	p.registerPrefix(token.WITH, p.parseWith)
+	p.registerPrefix(token.TRY, p.parseTry)
 	p.registerPrefix(token.FUNCTION, p.parseFunction)
 	p.registerPrefix(token.STRING, p.parseString)
 	p.registerPrefix(token.LSQUARE, p.parseArray)
 	p.registerPrefix(token.LBRACE, p.parseHash)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
 	// Infix: Map tokens --> parsing functions
 	p.infixMap = make(map[token.TokenType]parseInfix)
@@ -52,10 +74,18 @@ func BuildParser(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfix)
 	p.registerInfix(token.SLASH, p.parseInfix)
 	p.registerInfix(token.ASTERISK, p.parseInfix)
+	p.registerInfix(token.PERCENT, p.parseInfix)
 	p.registerInfix(token.EQ, p.parseInfix)
 	p.registerInfix(token.NOT_EQ, p.parseInfix)
 	p.registerInfix(token.LT, p.parseInfix)
 	p.registerInfix(token.GT, p.parseInfix)
+	p.registerInfix(token.LT_EQ, p.parseInfix)
+	p.registerInfix(token.GT_EQ, p.parseInfix)
+	p.registerInfix(token.AND, p.parseInfix)
+	p.registerInfix(token.OR, p.parseInfix)
+	p.registerInfix(token.IN, p.parseInfix)
+	p.registerInfix(token.NOT, p.parseNotIn)
+	p.registerInfix(token.PIPE, p.parsePipe)
 	p.registerInfix(token.LPAREN, p.parseCall)
 	p.registerInfix(token.LSQUARE, p.parseIndex)
 
@@ -110,34 +140,77 @@ func (p *Parser) registerInfix(t token.TokenType, f parseInfix) {
 	p.infixMap[t] = f
 }
 
+// Parse expressions starting with token type t using f (replacing any existing parse function)
+// f starts at the token of type t, and should end at the last token of the expression
+func (p *Parser) RegisterPrefix(t token.TokenType, f func() ast.Expression) {
+	p.registerPrefix(t, f)
+}
+
+// Parse infix operator of token type t using f, which gets the left side of the operator
+// Operators also need a precedence (SetPrecedence), otherwise they're never parsed as infix
+func (p *Parser) RegisterInfix(t token.TokenType, f func(ast.Expression) ast.Expression) {
+	p.registerInfix(t, f)
+}
+
+// Set precedence of infix operator of token type t for this parser
+func (p *Parser) SetPrecedence(t token.TokenType, precedence int) {
+	p.precedences[t] = precedence
+}
+
+// Get the token being parsed (for registered parse functions)
+func (p *Parser) CurrentToken() token.Token {
+	return p.currentToken
+}
+
+// Get the precedence of the token being parsed (for registered parse functions)
+func (p *Parser) CurrentPrecedence() int {
+	return p.getCurrentPrecedence()
+}
+
+// Parse expression starting at the current token, stopping at operators not binding tighter than precedence
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
 const (
 	_           int = iota // 0
 	LOWEST                 // 1
-	EQUALS                 // 2: ==
-	LESSGREATER            // 3: <,>
-	SUM                    // 4: +
-	PRODUCT                // 5: *
-	PREFIX                 // 6: -foo, !foo
-	CALL                   // 7: foo(bar)
-	INDEX                  // 8: array[index]
+	PIPE                   // 2: |>
+	OR                     // 3: ||
+	AND                    // 4: &&
+	EQUALS                 // 5: ==
+	LESSGREATER            // 6: <,>
+	SUM                    // 7: +
+	PRODUCT                // 8: *
+	PREFIX                 // 9: -foo, !foo
+	CALL                   // 10: foo(bar)
+	INDEX                  // 11: array[index]
 )
 
 // Maps token types --> precedences
 var precedencesMap = map[token.TokenType]int{
+	token.PIPE:     PIPE,
+	token.OR:       OR,
+	token.AND:      AND,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
+	token.IN:       LESSGREATER,
+	token.NOT:      LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LSQUARE:  INDEX,
 }
 
 func (p *Parser) getCurrentPrecedence() int {
-	precedence, ok := precedencesMap[p.currentToken.Type]
+	precedence, ok := p.precedences[p.currentToken.Type]
 	if ok {
 		return precedence
 	} else {
@@ -146,7 +219,7 @@ func (p *Parser) getCurrentPrecedence() int {
 }
 
 func (p *Parser) getNextPrecedence() int {
-	precedence, ok := precedencesMap[p.nextToken.Type]
+	precedence, ok := p.precedences[p.nextToken.Type]
 	if ok {
 		return precedence
 	} else {
@@ -165,8 +238,17 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	// Iterates over tokens in input until EOF
 	for p.currentToken.Type != token.EOF {
+		startToken := p.currentToken
+		numErrors := len(p.errors)
+
 		statement := p.parseStatement()
 
+		// Replace a broken statement with a marker, then skip to the next one
+		if len(p.errors) > numErrors {
+			statement = &ast.BadStatement{Token: startToken}
+			p.skipStatement()
+		}
+
 		if statement != nil {
 			prog.Statements = append(prog.Statements, statement)
 		}
@@ -177,6 +259,13 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return prog
 }
 
+// Helper method to advance to the end of the current statement
+func (p *Parser) skipStatement() {
+	for p.currentToken.Type != token.SEMICOLON && p.currentToken.Type != token.EOF {
+		p.GetNextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	if PRINT_PARSE {
 		color.Cyan("  CALL parser.parseStatement()")
@@ -184,21 +273,78 @@ func (p *Parser) parseStatement() ast.Statement {
 
 	switch p.currentToken.Type {
 	case token.LET:
+		if p.nextToken.Type == token.LSQUARE {
+			return p.parseDestructureStatement()
+		}
+		return p.parseLetStatement()
+	case token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.DEFER:
+		return p.parseDeferStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.BREAK:
+		statement := &ast.BreakStatement{Token: p.currentToken}
+		if p.nextToken.Type == token.SEMICOLON {
+			p.GetNextToken()
+		}
+		return statement
+	case token.CONTINUE:
+		statement := &ast.ContinueStatement{Token: p.currentToken}
+		if p.nextToken.Type == token.SEMICOLON {
+			p.GetNextToken()
+		}
+		return statement
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-// e.g. "let x = 5;"
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+// e.g. "let x = 5;", "const x = 5;" or "let a = 1, b = a + 1;"
+func (p *Parser) parseLetStatement() ast.Statement {
 	if PRINT_PARSE {
 		color.Cyan("    CALL parser.parseLetStatement()")
 	}
-	// "let"
-	statement := &ast.LetStatement{Token: p.currentToken}
+	// "let" or "const"
+	letToken := p.currentToken
+
+	// e.g. "a = 1" and "b = a + 1"
+	lets := []*ast.LetStatement{}
+	for {
+		let := p.parseLetBinding(letToken)
+		if let == nil {
+			return nil
+		}
+		lets = append(lets, let)
+
+		// ","
+		if p.nextToken.Type != token.COMMA {
+			break
+		}
+		p.GetNextToken()
+	}
+
+	// ";"
+	if p.nextToken.Type == token.SEMICOLON {
+		p.GetNextToken()
+	}
+
+	var statement ast.Statement = lets[0]
+	if len(lets) > 1 {
+		statement = &ast.MultiLetStatement{Token: letToken, Lets: lets}
+	}
+
+	if PRINT_PARSE {
+		color.Blue("    RET parser.parseLetStatement():%s", statement.String())
+	}
+	return statement
+}
+
+// Helper method to parse one "name = value" binding of a let statement
+func (p *Parser) parseLetBinding(letToken token.Token) *ast.LetStatement {
+	statement := &ast.LetStatement{Token: letToken}
 
 	// e.g. "x"
 	if !p.GetExpectNextToken(token.IDENT) {
@@ -215,13 +361,68 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.GetNextToken()
 	statement.Value = p.parseExpression(LOWEST)
 
+	// e.g. "let f = fn() { f() };"
+	if f, ok := statement.Value.(*ast.Function); ok {
+		f.Name = statement.Name.Value
+	}
+
+	return statement
+}
+
+// e.g. "let [a, b = 0] = arr;"
+func (p *Parser) parseDestructureStatement() *ast.DestructureStatement {
+	if PRINT_PARSE {
+		color.Cyan("    CALL parser.parseDestructureStatement()")
+	}
+	// "let"
+	statement := &ast.DestructureStatement{Token: p.currentToken}
+
+	// "["
+	p.GetNextToken()
+
+	for {
+		// e.g. "a"
+		if !p.GetExpectNextToken(token.IDENT) {
+			return nil
+		}
+		statement.Names = append(statement.Names, &ast.Identifier{p.currentToken, p.currentToken.Literal})
+
+		// e.g. "= 0"
+		var value ast.Expression
+		if p.nextToken.Type == token.ASSIGN {
+			p.GetNextToken()
+			p.GetNextToken()
+			value = p.parseExpression(LOWEST)
+		}
+		statement.Defaults = append(statement.Defaults, value)
+
+		if p.nextToken.Type != token.COMMA {
+			break
+		}
+		p.GetNextToken()
+	}
+
+	// "]"
+	if !p.GetExpectNextToken(token.RSQUARE) {
+		return nil
+	}
+
+	// "="
+	if !p.GetExpectNextToken(token.ASSIGN) {
+		return nil
+	}
+
+	// e.g. "arr"
+	p.GetNextToken()
+	statement.Value = p.parseExpression(LOWEST)
+
 	// ";"
 	if p.nextToken.Type == token.SEMICOLON {
 		p.GetNextToken()
 	}
 
 	if PRINT_PARSE {
-		color.Blue("    RET parser.parseLetStatement():%s", statement.String())
+		color.Blue("    RET parser.parseDestructureStatement():%s", statement.String())
 	}
 	return statement
 }
@@ -249,6 +450,70 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+// e.g. "defer print(x);"
+func (p *Parser) parseDeferStatement() *ast.DeferStatement {
+	if PRINT_PARSE {
+		color.Cyan("    CALL parser.parseDeferStatement()")
+	}
+	// "defer"
+	statement := &ast.DeferStatement{Token: p.currentToken}
+	p.GetNextToken()
+
+	// e.g. "print(x)"
+	statement.Value = p.parseExpression(LOWEST)
+
+	// ";"
+	if p.nextToken.Type == token.SEMICOLON {
+		p.GetNextToken()
+	}
+
+	if PRINT_PARSE {
+		color.Blue("    RET parser.parseDeferStatement():%s", statement.String())
+	}
+	return statement
+}
+
+// e.g. "while (x < 5) { let x = x + 1; }"
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	if PRINT_PARSE {
+		color.Cyan("    CALL parser.parseWhileStatement()")
+	}
+	// "while"
+	statement := &ast.WhileStatement{Token: p.currentToken}
+
+	// "("
+	if !p.GetExpectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	// e.g. "x < 5"
+	p.GetNextToken()
+	statement.Condition = p.parseExpression(LOWEST)
+
+	// ")"
+	if !p.GetExpectNextToken(token.RPAREN) {
+		return nil
+	}
+
+	// "{"
+	if !p.GetExpectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	// e.g. "let x = x + 1;"
+	statement.Body = p.parseBlockStatement()
+
+	// Optional semicolon
+	if p.nextToken.Type == token.SEMICOLON {
+		p.GetNextToken()
+	}
+
+	if PRINT_PARSE {
+		color.Blue("    RET parser.parseWhileStatement():%s", statement.String())
+	}
+	return statement
+}
+
 // Parse expression statements e.g. "5 + foo"
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if PRINT_PARSE {
@@ -354,6 +619,18 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return &ast.IntegerLiteral{p.currentToken, value}
 }
 
+// Parse float literal expressions e.g. "3.14"
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("couldn't parse %q as float", p.currentToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return &ast.FloatLiteral{p.currentToken, value}
+}
+
 // Parse prefix expressions e.g. "-add(1, 2)"
 func (p *Parser) parsePrefix() ast.Expression {
 	if PRINT_PARSE {
@@ -394,6 +671,49 @@ func (p *Parser) parseInfix(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// Parse negated membership e.g. "x not in arr" (operator is "not in")
+func (p *Parser) parseNotIn(left ast.Expression) ast.Expression {
+	if PRINT_PARSE {
+		color.Cyan("      CALL p.parseNotIn()")
+	}
+	// e.g. "x" and "not"
+	expression := &ast.Infix{Token: p.currentToken, Operator: "not in", Left: left}
+	precedence := p.getCurrentPrecedence()
+
+	// "in"
+	if !p.GetExpectNextToken(token.IN) {
+		return nil
+	}
+
+	// e.g. "arr"
+	p.GetNextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	if PRINT_PARSE {
+		color.Blue("      RET p.parseNotIn(): %s", expression.String())
+	}
+	return expression
+}
+
+// Parse pipes e.g. "x |> f" (same as "f(x)", so it's parsed as a call)
+func (p *Parser) parsePipe(left ast.Expression) ast.Expression {
+	if PRINT_PARSE {
+		color.Cyan("      CALL p.parsePipe()")
+	}
+	// "|>"
+	c := &ast.Call{Token: p.currentToken, Arguments: []ast.Expression{left}}
+
+	// e.g. "f"
+	precedence := p.getCurrentPrecedence()
+	p.GetNextToken()
+	c.Function = p.parseExpression(precedence)
+
+	if PRINT_PARSE {
+		color.Blue("      RET p.parsePipe(): %s", c.String())
+	}
+	return c
+}
+
 // Parse boolean expressions e.g. "true"
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.currentToken, Value: p.currentToken.Type == token.TRUE}
@@ -421,6 +741,107 @@ func (p *Parser) parseGrouped() ast.Expression {
 	}
 }
 
+// e.g. "with (x = 1, y = 2) { x + y }"
+func (p *Parser) parseWith() ast.Expression {
+	if PRINT_PARSE {
+		color.Cyan("      CALL p.parseWith()")
+	}
+	// "with"
+	expression := &ast.With{Token: p.currentToken}
+
+	// "("
+	if !p.GetExpectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	// e.g. "x = 1, y = 2"
+	for p.nextToken.Type != token.RPAREN {
+		if len(expression.Names) > 0 && !p.GetExpectNextToken(token.COMMA) {
+			return nil
+		}
+
+		if !p.GetExpectNextToken(token.IDENT) {
+			return nil
+		}
+		name := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+		if !p.GetExpectNextToken(token.ASSIGN) {
+			return nil
+		}
+
+		p.GetNextToken()
+		expression.Names = append(expression.Names, name)
+		expression.Values = append(expression.Values, p.parseExpression(LOWEST))
+	}
+
+	// ")"
+	p.GetNextToken()
+
+	// "{"
+	if !p.GetExpectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	// e.g. "x + y"
+	expression.Body = p.parseBlockStatement()
+
+	if PRINT_PARSE {
+		color.Blue("      RET p.parseWith(): %s", expression.String())
+	}
+	return expression
+}
+
+// Parse try expressions e.g. "try { int(s) } catch (e) { 0 }"
+func (p *Parser) parseTry() ast.Expression {
+	if PRINT_PARSE {
+		color.Cyan("      CALL p.parseTry()")
+	}
+	// "try"
+	expression := &ast.Try{Token: p.currentToken}
+
+	// "{"
+	if !p.GetExpectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	// e.g. "int(s)"
+	expression.Body = p.parseBlockStatement()
+
+	// "catch"
+	if !p.GetExpectNextToken(token.CATCH) {
+		return nil
+	}
+
+	// "("
+	if !p.GetExpectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	// e.g. "e"
+	if !p.GetExpectNextToken(token.IDENT) {
+		return nil
+	}
+	expression.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	// ")"
+	if !p.GetExpectNextToken(token.RPAREN) {
+		return nil
+	}
+
+	// "{"
+	if !p.GetExpectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	// e.g. "0"
+	expression.Handler = p.parseBlockStatement()
+
+	if PRINT_PARSE {
+		color.Blue("      RET p.parseTry(): %s", expression.String())
+	}
+	return expression
+}
+
 // Parse if expressions e.g. "if (4 < 5) { x } else { y }"
 func (p *Parser) parseIf() ast.Expression {
 	if PRINT_PARSE {
@@ -470,7 +891,7 @@ func (p *Parser) parseIf() ast.Expression {
 	return expression
 }
 
-// Parse function expressions e.g. "fn(x, y) { x + y; }"
+// Parse function expressions e.g. "fn(x, y) { x + y; }" or "fn(x, y) => x + y"
 func (p *Parser) parseFunction() ast.Expression {
 	if PRINT_PARSE {
 		color.Cyan("      CALL p.parseFunction()")
@@ -486,6 +907,13 @@ func (p *Parser) parseFunction() ast.Expression {
 	// e.g. "x, y"
 	f.Parameters = p.parseFunctionParameters()
 
+	// "=>": body is a single expression whose value is returned
+	if p.nextToken.Type == token.ARROW {
+		p.GetNextToken()
+		f.Body = p.parseArrowBody()
+		return f
+	}
+
 	// e.g. "{"
 	if !p.GetExpectNextToken(token.LBRACE) {
 		return nil
@@ -499,6 +927,47 @@ func (p *Parser) parseFunction() ast.Expression {
 	return f
 }
 
+// Parse macro literals e.g. "macro(x, y) { x + y; }"
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	if PRINT_PARSE {
+		color.Cyan("      CALL p.parseMacroLiteral()")
+	}
+	// "macro"
+	m := &ast.MacroLiteral{Token: p.currentToken}
+
+	// "("
+	if !p.GetExpectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	// e.g. "x, y"
+	m.Parameters = p.parseFunctionParameters()
+
+	// e.g. "{"
+	if !p.GetExpectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	m.Body = p.parseBlockStatement()
+
+	if PRINT_PARSE {
+		color.Blue("      RET p.parseMacroLiteral(): %s", m.String())
+	}
+	return m
+}
+
+// Helper method to wrap the expression after "=>" as a function body
+func (p *Parser) parseArrowBody() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.currentToken}
+
+	p.GetNextToken()
+	statement := &ast.ExpressionStatement{Token: p.currentToken}
+	statement.Expression = p.parseExpression(LOWEST)
+	block.Statements = []ast.Statement{statement}
+
+	return block
+}
+
 // Helper method to parse function parameters
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	if PRINT_PARSE {
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go_interpreter/ast"
 	"go_interpreter/lexer"
+	"go_interpreter/token"
 	"testing"
 )
 
@@ -36,6 +37,59 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestMultiLetStatement(t *testing.T) {
+	l := lexer.BuildLexer("let a = 1, b = a + 1, f = fn() { f() }; a")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 2, len(prog.Statements), "Expected number of statements")
+	statement, ok := prog.Statements[0].(*ast.MultiLetStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: MultiLetStatement, actual: %T", prog.Statements[0])
+	}
+
+	assert.Equal(t, 3, len(statement.Lets))
+	testLetStatement(t, statement.Lets[0], "a")
+	testLiteral(t, statement.Lets[0].Value, 1)
+	testLetStatement(t, statement.Lets[1], "b")
+	testInfix(t, statement.Lets[1].Value, "a", "+", 1)
+	assert.Equal(t, "f", statement.Lets[2].Value.(*ast.Function).Name)
+	assert.Equal(t, "let a = 1, b = (a + 1), f = fn()f();", statement.String())
+
+	// Every binding of a multiple const is const
+	prog = BuildParser(lexer.BuildLexer("const x = 1, y = 2;")).ParseProgram()
+	for _, let := range prog.Statements[0].(*ast.MultiLetStatement).Lets {
+		assert.True(t, let.IsConst(), let.Name.Value)
+	}
+
+	// Missing binding after ","
+	p = BuildParser(lexer.BuildLexer("let a = 1, ; let b = 2;"))
+	prog = p.ParseProgram()
+	assert.Equal(t, []string{"expected next token: IDENT, actual: ;"}, p.Errors())
+	testLetStatement(t, prog.Statements[1], "b")
+}
+
+func TestConstStatement(t *testing.T) {
+	l := lexer.BuildLexer("const x = 5;")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	statement, ok := prog.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: LetStatement, actual: %T", prog.Statements[0])
+	}
+
+	assert.True(t, statement.IsConst())
+	assert.Equal(t, "x", statement.Name.Value)
+	testLiteral(t, statement.Value, 5)
+	assert.Equal(t, "const x = 5;", statement.String())
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -66,6 +120,186 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestDeferStatement(t *testing.T) {
+	l := lexer.BuildLexer("defer print(x);")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	statement, ok := prog.Statements[0].(*ast.DeferStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: DeferStatement, actual: %T", prog.Statements[0])
+	}
+
+	assert.Equal(t, "defer print(x);", statement.String())
+}
+
+func TestDestructureStatement(t *testing.T) {
+	l := lexer.BuildLexer("let [a, b = 0] = arr;")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	statement, ok := prog.Statements[0].(*ast.DestructureStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: DestructureStatement, actual: %T", prog.Statements[0])
+	}
+
+	assert.Equal(t, 2, len(statement.Names))
+	testIdentifier(t, statement.Names[0], "a")
+	testIdentifier(t, statement.Names[1], "b")
+	assert.Nil(t, statement.Defaults[0])
+	testLiteral(t, statement.Defaults[1], 0)
+	testLiteral(t, statement.Value, "arr")
+}
+
+func TestWhileStatement(t *testing.T) {
+	l := lexer.BuildLexer("while (x < 5) { let x = x + 1; }")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	statement, ok := prog.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: WhileStatement, actual: %T", prog.Statements[0])
+	}
+
+	testInfix(t, statement.Condition, "x", "<", 5)
+	assert.Equal(t, 1, len(statement.Body.Statements))
+	testLetStatement(t, statement.Body.Statements[0], "x")
+
+	// Empty body
+	l = lexer.BuildLexer("while (false) {}")
+	p = BuildParser(l)
+	prog = p.ParseProgram()
+
+	checkParserErrors(t, p)
+	statement = prog.Statements[0].(*ast.WhileStatement)
+	assert.Equal(t, 0, len(statement.Body.Statements))
+
+	// Optional semicolon
+	l = lexer.BuildLexer("while (false) { 1 }; 2")
+	p = BuildParser(l)
+	prog = p.ParseProgram()
+
+	checkParserErrors(t, p)
+	assert.Equal(t, 2, len(prog.Statements), "Expected number of statements")
+}
+
+func TestWith(t *testing.T) {
+	l := lexer.BuildLexer("with (x = 1, y = x + 1) { x * y }")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	expression, ok := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.With)
+	if !ok {
+		t.Fatalf("Expected type of Expression: With, actual: %T", prog.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+
+	assert.Equal(t, 2, len(expression.Names))
+	assert.Equal(t, "x", expression.Names[0].Value)
+	assert.Equal(t, "y", expression.Names[1].Value)
+	testInfix(t, expression.Values[1], "x", "+", 1)
+	assert.Equal(t, "with(x = 1, y = (x + 1)) (x * y)", expression.String())
+
+	// No bindings
+	l = lexer.BuildLexer("with () { 1 }")
+	p = BuildParser(l)
+	prog = p.ParseProgram()
+
+	checkParserErrors(t, p)
+	expression = prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.With)
+	assert.Equal(t, 0, len(expression.Names))
+
+	// Missing "="
+	l = lexer.BuildLexer("with (x 1) { x }")
+	p = BuildParser(l)
+	p.ParseProgram()
+	assert.NotEqual(t, 0, len(p.Errors()), "Expected parser errors")
+}
+
+func TestTry(t *testing.T) {
+	l := lexer.BuildLexer("try { int(s) } catch (e) { len(e) }")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	expression, ok := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.Try)
+	if !ok {
+		t.Fatalf("Expected type of Expression: Try, actual: %T", prog.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+
+	assert.Equal(t, "e", expression.Name.Value)
+	assert.Equal(t, 1, len(expression.Body.Statements))
+	assert.Equal(t, 1, len(expression.Handler.Statements))
+	assert.Equal(t, "try int(s) catch(e) len(e)", expression.String())
+
+	// Missing catch, or catch without a name
+	for _, input := range []string{"try { 1 }", "try { 1 } catch { 2 }", "try { 1 } catch () { 2 }"} {
+		p = BuildParser(lexer.BuildLexer(input))
+		p.ParseProgram()
+		assert.NotEqual(t, 0, len(p.Errors()), input)
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	l := lexer.BuildLexer("while (true) { if (x) { break; } continue }")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	statement := prog.Statements[0].(*ast.WhileStatement)
+	assert.Equal(t, 2, len(statement.Body.Statements))
+
+	_, ok := statement.Body.Statements[1].(*ast.ContinueStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: ContinueStatement, actual: %T", statement.Body.Statements[1])
+	}
+
+	consequence := statement.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.If).Consequence
+	_, ok = consequence.Statements[0].(*ast.BreakStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: BreakStatement, actual: %T", consequence.Statements[0])
+	}
+	assert.Equal(t, "whiletrue ifx break;continue;", statement.String())
+}
+
+func TestMacroLiteral(t *testing.T) {
+	l := lexer.BuildLexer("macro(x, y) { x + y; }")
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	statement, ok := prog.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: ExpressionStatement, actual: %T", prog.Statements[0])
+	}
+
+	macro, ok := statement.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("Expected type of Expression: MacroLiteral, actual: %T", statement.Expression)
+	}
+
+	assert.Equal(t, 2, len(macro.Parameters), "Expected number of parameters")
+	assert.Equal(t, "x", macro.Parameters[0].String())
+	assert.Equal(t, "y", macro.Parameters[1].String())
+	assert.Equal(t, "(x + y)", macro.Body.String())
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foo;"
 
@@ -115,6 +349,31 @@ func TestIntegerValueExpression(t *testing.T) {
 	assert.Equal(t, literal.TokenLiteral(), "5", "Expected TokenLiteral() of literal")
 }
 
+func TestFloatValueExpression(t *testing.T) {
+	input := "3.25;"
+
+	l := lexer.BuildLexer(input)
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+
+	statement, ok := prog.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected Statement type: ExpressionStatement, actual: %T", prog.Statements[0])
+	}
+
+	literal, ok := statement.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("Expected expression type: FloatLiteral, actual: %T", statement.Expression)
+	}
+
+	assert.Equal(t, literal.Value, 3.25, "Expected value of literal")
+	assert.Equal(t, literal.TokenLiteral(), "3.25", "Expected TokenLiteral() of literal")
+}
+
 func TestPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -165,8 +424,11 @@ func TestInfixExpressions(t *testing.T) {
 		{"1 - 2;", 1, "-", 2},
 		{"1 * 2;", 1, "*", 2},
 		{"1 / 2;", 1, "/", 2},
+		{"1 % 2;", 1, "%", 2},
 		{"1 > 2;", 1, ">", 2},
 		{"1 < 2;", 1, "<", 2},
+		{"1 <= 2;", 1, "<=", 2},
+		{"1 >= 2;", 1, ">=", 2},
 		{"1 == 2;", 1, "==", 2},
 		{"1 != 2;", 1, "!=", 2},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
@@ -180,6 +442,8 @@ func TestInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"foobar in barfoo;", "foobar", "in", "barfoo"},
+		{"foobar not in barfoo;", "foobar", "not in", "barfoo"},
 	}
 
 	for _, test := range infixTests {
@@ -228,6 +492,38 @@ func TestOperatorPrecedence(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
+		{
+			"a + 1 in b",
+			"((a + 1) in b)",
+		},
+		{
+			"a not in b == false",
+			"((a not in b) == false)",
+		},
+		{
+			"x |> f |> g",
+			"g(f(x))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == 1 && b < 2",
+			"((a == 1) && (b < 2))",
+		},
+		{
+			"a || b |> f",
+			"f((a || b))",
+		},
+		{
+			"1 + 2 |> fn(x) { x * 2 }",
+			"fn(x)(x * 2)((1 + 2))",
+		},
 	}
 
 	for _, test := range tests {
@@ -324,6 +620,70 @@ func TestIfElseExpression(t *testing.T) {
 	testIdentifier(t, alternative.Expression, "y")
 }
 
+func TestCustomPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// + binds as tightly as *, so operators group left to right
+		{"1 + 2 * 3", "((1 + 2) * 3)"},
+		{"1 * 2 + 3", "((1 * 2) + 3)"},
+		// == binds tighter than +
+		{"a + b == c", "(a + (b == c))"},
+		// Unchanged operators keep their default precedence
+		{"a - b * c", "(a - (b * c))"},
+	}
+
+	options := Options{Precedences: map[token.TokenType]int{
+		token.PLUS: PRODUCT,
+		token.EQ:   PREFIX,
+	}}
+
+	for _, test := range tests {
+		p := BuildParserWithOptions(lexer.BuildLexer(test.input), options)
+		prog := p.ParseProgram()
+
+		checkParserErrors(t, p)
+		assert.Equal(t, test.expected, prog.String(), test.input)
+	}
+
+	// Other parsers still use the default precedences
+	p := BuildParser(lexer.BuildLexer("1 + 2 * 3"))
+	assert.Equal(t, "(1 + (2 * 3))", p.ParseProgram().String())
+}
+
+func TestRegisterParseFunctions(t *testing.T) {
+	p := BuildParser(lexer.BuildLexer("a : b + c : d; *x"))
+
+	// Right associative ":" binding looser than "+", e.g. "a : b : c" is "a : (b : c)"
+	p.SetPrecedence(token.COLON, EQUALS)
+	p.RegisterInfix(token.COLON, func(left ast.Expression) ast.Expression {
+		expression := &ast.Infix{Token: p.CurrentToken(), Operator: ":", Left: left}
+		precedence := p.CurrentPrecedence()
+		p.GetNextToken()
+		expression.Right = p.ParseExpression(precedence - 1)
+		return expression
+	})
+
+	// "*x" dereferences x
+	p.RegisterPrefix(token.ASTERISK, func() ast.Expression {
+		expression := &ast.Prefix{Token: p.CurrentToken(), Operator: "*"}
+		p.GetNextToken()
+		expression.Value = p.ParseExpression(PREFIX)
+		return expression
+	})
+
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+	assert.Equal(t, "(a : ((b + c) : d))(*x)", prog.String())
+
+	// Other parsers don't know about the registered functions
+	p = BuildParser(lexer.BuildLexer("*x"))
+	p.ParseProgram()
+	assert.Equal(t, []string{"missing prefix function for *"}, p.Errors())
+}
+
 func TestFunctionExpression(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -355,6 +715,38 @@ func TestFunctionExpression(t *testing.T) {
 	testInfix(t, bodyStatement.Expression, "x", "+", "y")
 }
 
+func TestArrowFunctionExpression(t *testing.T) {
+	input := `map(arr, fn(x, y) => x + y);`
+
+	l := lexer.BuildLexer(input)
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 1, len(prog.Statements), "Expected number of statements")
+	statement := prog.Statements[0].(*ast.ExpressionStatement)
+	call, ok := statement.Expression.(*ast.Call)
+	if !ok {
+		t.Fatalf("Expected Expression type: Call, actual: %T", statement.Expression)
+	}
+	assert.Equal(t, 2, len(call.Arguments), "Expected number of arguments")
+
+	function, ok := call.Arguments[1].(*ast.Function)
+	if !ok {
+		t.Fatalf("Expected Expression type: Function, actual: %T", call.Arguments[1])
+	}
+	assert.Equal(t, 2, len(function.Parameters), "Expected number of parameters")
+
+	assert.Equal(t, 1, len(function.Body.Statements), "Expected number of body statements")
+	bodyStatement, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected function body statement type: ExpressionStatement")
+	}
+
+	testInfix(t, bodyStatement.Expression, "x", "+", "y")
+}
+
 func TestCallExpression(t *testing.T) {
 	input := "add(1, 2*3, 4+5);"
 
@@ -401,6 +793,28 @@ func TestString(t *testing.T) {
 	assert.Equal(t, "hello world", literal.Value, "Expceted value of string")
 }
 
+func TestPartialProgram(t *testing.T) {
+	input := "let x = 5; let = 10; let y = x + 1;"
+
+	l := lexer.BuildLexer(input)
+	p := BuildParser(l)
+	prog := p.ParseProgram()
+
+	assert.Equal(t, 1, len(p.Errors()), "Expected number of errors")
+	assert.Equal(t, 3, len(prog.Statements), "Expected number of statements")
+
+	testLetStatement(t, prog.Statements[0], "x")
+	testLiteral(t, prog.Statements[0].(*ast.LetStatement).Value, 5)
+
+	_, ok := prog.Statements[1].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("Expected type of Statement: BadStatement, actual: %T", prog.Statements[1])
+	}
+
+	testLetStatement(t, prog.Statements[2], "y")
+	testInfix(t, prog.Statements[2].(*ast.LetStatement).Value, "x", "+", 1)
+}
+
 // Helper method for checking parser errors
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
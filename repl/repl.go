@@ -2,6 +2,7 @@ package repl
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"go_interpreter/compiler"
 	"go_interpreter/evaluator"
@@ -10,16 +11,23 @@ import (
 	"go_interpreter/parser"
 	"go_interpreter/vm"
 	"io"
+	"os"
+	"sort"
+	"strings"
 )
 
 const PROMPT = ">> "
+const LAST_RESULT = "_"        // REPL binds the result of the previous line to this name
+const CONSTANTS = ":constants" // REPL command listing the compiler's constant pool
+const SCOPES = ":scopes"       // REPL command listing the interpreter's environment chain
 
+// Read-eval-print loop: result of each line is printed automatically
 func StartLoop(engine *string, in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
 	// Compiler
 	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalCapacity)
+	globals := []object.Object{}
 	symbolTable := compiler.BuildSymbolTable()
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
@@ -27,9 +35,10 @@ func StartLoop(engine *string, in io.Reader, out io.Writer) {
 
 	// Interpreter
 	env := object.BuildEnvironment()
+	macroEnv := object.BuildEnvironment()
 
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Fprintf(out, PROMPT)
 
 		// Get user input
 		scanned := scanner.Scan()
@@ -39,6 +48,24 @@ func StartLoop(engine *string, in io.Reader, out io.Writer) {
 			return
 		}
 
+		if scanner.Text() == CONSTANTS {
+			if *engine == "vm" {
+				io.WriteString(out, formatConstants(constants))
+			} else {
+				io.WriteString(out, CONSTANTS+" is only available with the vm engine\n")
+			}
+			continue
+		}
+
+		if scanner.Text() == SCOPES {
+			if *engine == "eval" {
+				io.WriteString(out, formatScopes(env.Chain()))
+			} else {
+				io.WriteString(out, SCOPES+" is only available with the eval engine\n")
+			}
+			continue
+		}
+
 		// Lexer
 		l := lexer.BuildLexer(scanner.Text())
 
@@ -50,13 +77,21 @@ func StartLoop(engine *string, in io.Reader, out io.Writer) {
 			continue
 		}
 
+		// Macros (definitions are kept across lines)
+		evaluator.DefineMacros(prog, macroEnv)
+		expanded := evaluator.ExpandMacros(prog, macroEnv)
+
 		if *engine == "vm" {
-			// Compiler
-			c := compiler.BuildStatefulCompiler(symbolTable, constants)
-			err := c.Compile(prog)
+			// Compiler (into a copy of the symbol table, so names from a line that fails to compile aren't kept)
+			// Its warnings aren't shown, since a later line may use a name this one binds
+			scratch := symbolTable.Clone()
+			c := compiler.BuildStatefulCompiler(scratch, constants)
+			err := c.Compile(expanded)
 			if err != nil {
 				fmt.Fprintf(out, "Compile-time error: %s\n", err)
+				continue
 			}
+			symbolTable = scratch
 
 			// VM
 			bytecode := c.Bytecode()
@@ -66,20 +101,165 @@ func StartLoop(engine *string, in io.Reader, out io.Writer) {
 			if err != nil {
 				fmt.Fprintf(out, "Run-time error: %s\n", err)
 			}
+			globals = machine.Globals()
 			lastPopped := machine.LastPopped()
-			io.WriteString(out, lastPopped.Inspect())
-			io.WriteString(out, "\n")
+			if lastPopped != nil {
+				io.WriteString(out, lastPopped.Inspect())
+				io.WriteString(out, "\n")
+			}
+
+			if err == nil && isResult(lastPopped) {
+				globals = bindGlobal(symbolTable, globals, LAST_RESULT, lastPopped)
+			}
 		} else {
 			// Evaluator
-			result := evaluator.Eval(prog, env)
-			if result != nil {
+			result := evaluator.Eval(expanded, env)
+			if errObj, ok := result.(*object.Error); ok {
+				io.WriteString(out, errObj.Describe())
+				io.WriteString(out, "\n")
+			} else if result != nil {
 				io.WriteString(out, result.Inspect())
 				io.WriteString(out, "\n")
 			}
+
+			if isResult(result) {
+				env.Set(LAST_RESULT, result)
+			}
 		}
 	}
 }
 
+// Run a script file: results aren't printed, only errors (and, with the vm engine, compiler warnings) are written to out
+func RunFile(engine *string, path string, out io.Writer) error {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Lexer
+	l := lexer.BuildLexer(string(input))
+
+	// Parser
+	p := parser.BuildParser(l)
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors())
+		return fmt.Errorf("%d parser errors", len(p.Errors()))
+	}
+
+	// Macros
+	macroEnv := object.BuildEnvironment()
+	evaluator.DefineMacros(prog, macroEnv)
+	expanded := evaluator.ExpandMacros(prog, macroEnv)
+
+	if *engine == "vm" {
+		// Compiler
+		c := compiler.BuildCompiler()
+		err := c.Compile(expanded)
+		if err != nil {
+			fmt.Fprintf(out, "Compile-time error: %s\n", err)
+			return err
+		}
+		for _, warning := range c.Warnings() {
+			fmt.Fprintf(out, "Warning: %s\n", warning)
+		}
+
+		// VM
+		machine := vm.BuildVM(c.Bytecode())
+		err = machine.Run()
+		if err != nil {
+			fmt.Fprintf(out, "Run-time error: %s\n", err)
+			return err
+		}
+	} else {
+		// Evaluator
+		result := evaluator.Eval(expanded, object.BuildEnvironment())
+		errObj, ok := result.(*object.Error)
+		if ok {
+			io.WriteString(out, errObj.Describe()+"\n")
+			return fmt.Errorf("%s", errObj.Message)
+		}
+		panicObj, ok := result.(*object.Panic)
+		if ok {
+			io.WriteString(out, panicObj.Inspect()+"\n")
+			return fmt.Errorf("%s", panicObj.Inspect())
+		}
+	}
+
+	return nil
+}
+
+// Helper function to check if a value should be bound to LAST_RESULT (like Python, skip null)
+func isResult(obj object.Object) bool {
+	return obj != nil && obj.Type() != object.ERROR_OBJECT &&
+		obj.Type() != object.PANIC_OBJECT && obj.Type() != object.NULL_OBJECT
+}
+
+// Helper function to set a global binding from outside the compiled program
+func bindGlobal(s *compiler.SymbolTable, globals []object.Object,
+	name string, value object.Object) []object.Object {
+	symbol, ok := s.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = s.Define(name)
+	}
+
+	for len(globals) <= symbol.Index {
+		globals = append(globals, nil)
+	}
+	globals[symbol.Index] = value
+
+	return globals
+}
+
+// Helper function to list a constant pool, one "index: TYPE value" line per constant
+// Compiled functions are followed by their indented disassembly
+func formatConstants(constants []object.Object) string {
+	if len(constants) == 0 {
+		return "no constants\n"
+	}
+
+	var out bytes.Buffer
+	for i, constant := range constants {
+		fmt.Fprintf(&out, "%d: %s %s\n", i, constant.Type(), constant.Inspect())
+
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			for _, line := range strings.SplitAfter(fn.Disassemble(), "\n") {
+				if line != "" {
+					out.WriteString("    " + line)
+				}
+			}
+		}
+	}
+
+	return out.String()
+}
+
+// Helper function to list an environment chain, one "depth: name = value, ..." line per environment
+// Depth 0 is the innermost environment, names are sorted
+func formatScopes(chain []map[string]object.Object) string {
+	var out bytes.Buffer
+	for depth, bindings := range chain {
+		names := make([]string, 0, len(bindings))
+		for name := range bindings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = name + " = " + bindings[name].Inspect()
+		}
+
+		if len(pairs) == 0 {
+			fmt.Fprintf(&out, "%d: (empty)\n", depth)
+		} else {
+			fmt.Fprintf(&out, "%d: %s\n", depth, strings.Join(pairs, ", "))
+		}
+	}
+
+	return out.String()
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
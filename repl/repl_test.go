@@ -0,0 +1,206 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"go_interpreter/bytecode"
+	"go_interpreter/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoopPrintsResults(t *testing.T) {
+	for _, engine := range []string{"eval", "vm"} {
+		var out bytes.Buffer
+		StartLoop(&engine, strings.NewReader("let x = 2;\nx + 1\n"), &out)
+
+		assert.Contains(t, out.String(), PROMPT+"3\n", engine)
+	}
+}
+
+func TestLoopBindsLastResult(t *testing.T) {
+	for _, engine := range []string{"eval", "vm"} {
+		var out bytes.Buffer
+		input := "1 + 2\n_ + 1\n_ * 2\nlet _ = 10;\n_\n"
+		StartLoop(&engine, strings.NewReader(input), &out)
+
+		assert.Contains(t, out.String(), PROMPT+"3\n"+PROMPT+"4\n"+PROMPT+"8\n", engine)
+		assert.Contains(t, out.String(), PROMPT+"10\n", engine)
+	}
+}
+
+func TestLoopReportsUndefinedVariable(t *testing.T) {
+	defer func() { object.Output = os.Stdout }()
+
+	engine := "vm"
+	var out bytes.Buffer
+	object.Output = &out
+	StartLoop(&engine, strings.NewReader("print(\"ran\") + x\nlet x = 2;\nx + 1\n"), &out)
+
+	// Nothing is run for a line that doesn't compile
+	assert.Contains(t, out.String(), PROMPT+"Compile-time error: undefined variable x\n"+PROMPT)
+	assert.NotContains(t, out.String(), "ran")
+	assert.Contains(t, out.String(), PROMPT+"3\n")
+}
+
+func TestLoopForgetsNamesFromFailedLine(t *testing.T) {
+	engine := "vm"
+	var out bytes.Buffer
+	StartLoop(&engine, strings.NewReader("let q = 5;\nlet a = 1; b\nlet c = 2;\na + 1\n"), &out)
+
+	// a was never set, since its line didn't run
+	assert.Contains(t, out.String(), PROMPT+"Compile-time error: undefined variable b\n"+PROMPT)
+	assert.Contains(t, out.String(), PROMPT+"Compile-time error: undefined variable a\n"+PROMPT)
+}
+
+func TestFormatConstants(t *testing.T) {
+	constants := []object.Object{
+		&object.Integer{Value: 1},
+		&object.String{Value: "hello"},
+		&object.Integer{Value: 42},
+	}
+
+	assert.Equal(t, "0: INTEGER 1\n1: STRING hello\n2: INTEGER 42\n", formatConstants(constants))
+	assert.Equal(t, "no constants\n", formatConstants([]object.Object{}))
+
+	fn := &object.CompiledFunction{
+		Instructions:  append(bytecode.Make(bytecode.OpGetLocal, 0), bytecode.Make(bytecode.OpReturnValue)...),
+		NumLocals:     1,
+		NumParameters: 1,
+	}
+	expected := "0: COMPILED_FUNCTION CompiledFunction[1 params, 1 locals]\n    0000 OpGetLocal 0\n    0002 OpReturnValue\n"
+	assert.Equal(t, expected, formatConstants([]object.Object{fn}))
+}
+
+func TestLoopListsConstants(t *testing.T) {
+	engine := "vm"
+	var out bytes.Buffer
+	StartLoop(&engine, strings.NewReader("let x = 2;\nx + 3\n:constants\n"), &out)
+
+	assert.Contains(t, out.String(), PROMPT+"0: INTEGER 2\n1: INTEGER 3\n")
+
+	engine = "eval"
+	out.Reset()
+	StartLoop(&engine, strings.NewReader(":constants\n"), &out)
+
+	assert.Contains(t, out.String(), ":constants is only available with the vm engine")
+}
+
+func TestFormatScopes(t *testing.T) {
+	chain := []map[string]object.Object{
+		{},
+		{"y": &object.Integer{Value: 2}, "x": &object.String{Value: "a"}},
+	}
+
+	assert.Equal(t, "0: (empty)\n1: x = a, y = 2\n", formatScopes(chain))
+}
+
+func TestLoopListsScopes(t *testing.T) {
+	engine := "eval"
+	var out bytes.Buffer
+	StartLoop(&engine, strings.NewReader("let x = 2;\n:scopes\n"), &out)
+
+	assert.Contains(t, out.String(), PROMPT+"0: x = 2\n")
+
+	engine = "vm"
+	out.Reset()
+	StartLoop(&engine, strings.NewReader(":scopes\n"), &out)
+
+	assert.Contains(t, out.String(), ":scopes is only available with the eval engine")
+}
+
+const unlessMacro = `let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };`
+
+func TestLoopExpandsMacros(t *testing.T) {
+	for _, engine := range []string{"eval", "vm"} {
+		var out bytes.Buffer
+		input := unlessMacro + "\nunless(1 > 2, \"yes\", \"no\")\n"
+		StartLoop(&engine, strings.NewReader(input), &out)
+
+		assert.Contains(t, out.String(), PROMPT+"yes\n", engine)
+	}
+}
+
+func TestRunFileExpandsMacros(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	input := unlessMacro + "\nunless(1 > 2, print(\"yes\"), print(\"no\"));\n"
+	err := os.WriteFile(path, []byte(input), 0644)
+	if err != nil {
+		t.Fatalf("Couldn't write script: %s", err)
+	}
+
+	defer func() { object.Output = os.Stdout }()
+
+	for _, engine := range []string{"eval", "vm"} {
+		var out, printed bytes.Buffer
+		object.Output = &printed
+		err := RunFile(&engine, path, &out)
+
+		assert.Equal(t, nil, err, engine)
+		assert.Equal(t, "", out.String(), engine)
+		assert.Equal(t, "yes\n", printed.String(), engine)
+	}
+}
+
+func TestRunFileIsSilent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	err := os.WriteFile(path, []byte("let x = 2;\nx + 1;\n"), 0644)
+	if err != nil {
+		t.Fatalf("Couldn't write script: %s", err)
+	}
+
+	for _, engine := range []string{"eval", "vm"} {
+		var out bytes.Buffer
+		err := RunFile(&engine, path, &out)
+
+		assert.Equal(t, nil, err, engine)
+		assert.Equal(t, "", out.String(), engine)
+	}
+}
+
+func TestRunFileWarnsAboutUnusedVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	err := os.WriteFile(path, []byte("let x = 2;\nlet y = 3;\nx + 1;\n"), 0644)
+	if err != nil {
+		t.Fatalf("Couldn't write script: %s", err)
+	}
+
+	engine := "vm"
+	var out bytes.Buffer
+	err = RunFile(&engine, path, &out)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Warning: line 2, column 5: unused variable y\n", out.String())
+}
+
+func TestRunFileReportsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	err := os.WriteFile(path, []byte("5 + true;"), 0644)
+	if err != nil {
+		t.Fatalf("Couldn't write script: %s", err)
+	}
+
+	engine := "eval"
+	var out bytes.Buffer
+	err = RunFile(&engine, path, &out)
+
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, "ERROR: line 1, column 3: type mismatch: INTEGER + BOOLEAN\n", out.String())
+}
+
+func TestRunFileDoesNotBindLastResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	err := os.WriteFile(path, []byte("1 + 2;\n_ + 1;\n"), 0644)
+	if err != nil {
+		t.Fatalf("Couldn't write script: %s", err)
+	}
+
+	engine := "eval"
+	var out bytes.Buffer
+	err = RunFile(&engine, path, &out)
+
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, "ERROR: line 2, column 1: identifier not found: _\n", out.String())
+}
@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 // Possible types of tokens
@@ -11,6 +13,7 @@ const (
 	// Function/variable names & values
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	// Operators
@@ -20,10 +23,16 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	ARROW    = "=>"
+	AND      = "&&"
+	OR       = "||"
 
 	// Delimiters
 	COMMA     = ","
@@ -35,34 +44,65 @@ const (
 	LSQUARE   = "["
 	RSQUARE   = "]"
 	COLON     = ":"
+	PIPE      = "|>"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	DEFER    = "DEFER"
+	MACRO    = "MACRO"
+	IN       = "IN"
+	NOT      = "NOT"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	WITH     = "WITH"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
 )
 
 // Small, easily categorizable data structures
 type Token struct {
 	Type    TokenType // Type of token
 	Literal string    // Literal value of token
+	Line    int       // Source line the token starts on (1-based)
+	Column  int       // Source column the token starts on (1-based, in characters)
+}
+
+// e.g. LET("let")
+func (t Token) String() string {
+	return fmt.Sprintf("%s(%q)", t.Type, t.Literal)
 }
 
-// Special identifiers
+// Special identifiers (every keyword is added here, and only here)
 var specialIdentifiers = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"defer":    DEFER,
+	"macro":    MACRO,
+	"in":       IN,
+	"not":      NOT,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"with":     WITH,
+	"try":      TRY,
+	"catch":    CATCH,
 }
 
+// Classify a word as a keyword or an identifier (IDENT)
 func GetIdentifier(input string) TokenType {
 	tokenType, ok := specialIdentifiers[input]
 	if ok {
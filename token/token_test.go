@@ -0,0 +1,19 @@
+package token
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	assert.Equal(t, `LET("let")`, Token{Type: LET, Literal: "let", Line: 1}.String())
+	assert.Equal(t, `STRING("a \"b\"")`, Token{Type: STRING, Literal: `a "b"`}.String())
+	assert.Equal(t, `EOF("")`, Token{Type: EOF}.String())
+}
+
+func TestGetIdentifier(t *testing.T) {
+	assert.Equal(t, TokenType(WHILE), GetIdentifier("while"))
+	assert.Equal(t, TokenType(FUNCTION), GetIdentifier("fn"))
+	assert.Equal(t, TokenType(IDENT), GetIdentifier("whilst"))
+	assert.Equal(t, TokenType(IDENT), GetIdentifier("While"))
+}
@@ -7,15 +7,16 @@ import (
 
 // Holds data relevant to execution
 type Frame struct {
-	fn          *object.CompiledFunction // Compiled function referenced by frame
-	ip          int                      // Instruction pointer to the compiled function
-	basePointer int                      // Bottom of stack of current call frame
+	cl          *object.Closure // Closure referenced by frame
+	ip          int             // Instruction pointer to the closure's compiled function
+	basePointer int             // Bottom of stack of current call frame
+	loops       []int           // Stack pointer at the start of each loop being run (innermost last)
 }
 
-func BuildFrame(fn *object.CompiledFunction, basePointer int) *Frame {
-	return &Frame{fn, -1, basePointer}
+func BuildFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
 }
 
 func (f *Frame) Instructions() bytecode.Instructions {
-	return f.fn.Instructions
+	return f.cl.Fn.Instructions
 }
@@ -6,41 +6,76 @@ import (
 	"go_interpreter/bytecode"
 	"go_interpreter/compiler"
 	"go_interpreter/object"
+	"strings"
 )
 
 var PRINT_VM = false
 
 const stackCapacity = 2048
-const GlobalCapacity = 65536 // Upper limit on number of global bindings
-const frameCapacity = 1024   // Upper limit on number of frames
+const GlobalCapacity = 65536     // Upper limit on number of global bindings
+const initialGlobalCapacity = 16 // Globals grow on demand from here
+const frameCapacity = 1024       // Upper limit on number of frames
 
-var True = &object.Boolean{Value: true}
-var False = &object.Boolean{Value: false}
-var Null = &object.Null{}
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
 
 type VM struct {
 	constants    []object.Object // Constants generated by compiler
 	stack        []object.Object // Stack for operands
 	stackPointer int             // stack[stackPointer-1] is top of stack
 	globals      []object.Object // Globals
+	globalSize   int             // Upper limit on number of globals
 	frames       []*Frame        // Stack of frames
 	framesIndex  int             // Top of stack of frames
+
+	opcodeCounts map[bytecode.Opcode]int // Times each opcode was executed (nil if not counting)
+}
+
+// Settings for a VM (zero values use the defaults)
+type Options struct {
+	StackSize    int  // Number of slots on the operand stack
+	GlobalSize   int  // Number of global bindings
+	CountOpcodes bool // Count how many times each opcode is executed
 }
 
 func BuildVM(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
-	mainFrame := BuildFrame(mainFn, 0)
+	return BuildVMWithOptions(bytecode, Options{})
+}
+
+func BuildVMWithOptions(b *compiler.Bytecode, options Options) *VM {
+	if options.StackSize <= 0 {
+		options.StackSize = stackCapacity
+	}
+	if options.GlobalSize <= 0 || options.GlobalSize > GlobalCapacity {
+		options.GlobalSize = GlobalCapacity
+	}
+
+	mainFn := &object.CompiledFunction{Instructions: b.Instructions}
+	mainFrame := BuildFrame(&object.Closure{Fn: mainFn}, 0)
 	frames := make([]*Frame, frameCapacity)
 	frames[0] = mainFrame
 
-	return &VM{
-		constants:    bytecode.Constants,
-		stack:        make([]object.Object, stackCapacity),
+	vm := &VM{
+		constants:    b.Constants,
+		stack:        make([]object.Object, options.StackSize),
 		stackPointer: 0,
-		globals:      make([]object.Object, GlobalCapacity),
+		globals:      make([]object.Object, 0, initialGlobalCapacity),
+		globalSize:   options.GlobalSize,
 		frames:       frames,
 		framesIndex:  1, // Since mainFrame is already on the frame stack
 	}
+
+	if options.CountOpcodes {
+		vm.opcodeCounts = make(map[bytecode.Opcode]int)
+	}
+
+	return vm
+}
+
+// Get number of times each opcode was executed (nil unless Options.CountOpcodes is set)
+func (vm *VM) OpcodeCounts() map[bytecode.Opcode]int {
+	return vm.opcodeCounts
 }
 
 func BuildStatefulVM(bytecode *compiler.Bytecode, g []object.Object) *VM {
@@ -49,6 +84,25 @@ func BuildStatefulVM(bytecode *compiler.Bytecode, g []object.Object) *VM {
 	return vm
 }
 
+// Rewind VM to the start of its program, reusing the stack and globals
+func (vm *VM) Reset(keepGlobals bool) {
+	vm.frames[0].ip = -1
+	vm.framesIndex = 1
+	vm.stackPointer = 0
+
+	if !keepGlobals {
+		for i := range vm.globals {
+			vm.globals[i] = nil
+		}
+		vm.globals = vm.globals[:0]
+	}
+}
+
+// Get globals (may have grown while running, so callers keeping state should re-read them)
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex-1]
 }
@@ -65,183 +119,303 @@ func (vm *VM) popFrame() *Frame {
 
 // Fetch-decode-execute cycle (instruction cycle)
 func (vm *VM) Run() error {
-	var ip int
-	var instructions bytecode.Instructions
-	var op bytecode.Opcode
+	for {
+		done, err := vm.Step()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// Execute one instruction (done is true once the program has no instructions left)
+func (vm *VM) Step() (bool, error) {
+	if vm.finished() {
+		return true, nil
+	}
+
+	if PRINT_VM {
+		color.Red("On frame %v", vm.framesIndex-1)
+	}
+
+	// Fetch
+	vm.currentFrame().ip++
+	ip := vm.currentFrame().ip
+	instructions := vm.currentFrame().Instructions()
+	op := bytecode.Opcode(instructions[ip])
 
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
-		if PRINT_VM {
-			color.Red("On frame %v", vm.framesIndex-1)
+	if PRINT_VM {
+		def, _ := bytecode.Lookup(byte(op))
+		color.Cyan("Current opcode: %s", def.Name)
+	}
+
+	if vm.opcodeCounts != nil {
+		vm.opcodeCounts[op]++
+	}
+
+	// Decode & Execute
+	switch op {
+	case bytecode.OpGetBuiltin:
+		builtinIndex := bytecode.ReadUint8(instructions[ip+1:])
+		vm.currentFrame().ip += 1
+		definition := object.Builtins[builtinIndex]
+		err := vm.push(definition.Builtin)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpCurrentClosure:
+		err := vm.push(vm.currentFrame().cl)
+		if err != nil {
+			return false, err
 		}
+	case bytecode.OpGetFree:
+		freeIndex := bytecode.ReadUint8(instructions[ip+1:])
+		vm.currentFrame().ip += 1
+		err := vm.push(vm.currentFrame().cl.Free[freeIndex])
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpClosure:
+		constIndex := bytecode.ReadUint16(instructions[ip+1:])
+		numFree := bytecode.ReadUint8(instructions[ip+3:])
+		vm.currentFrame().ip += 3
+		err := vm.pushClosure(int(constIndex), int(numFree))
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpGetLocal:
+		localIndex := bytecode.ReadUint8(instructions[ip+1:])
+		vm.currentFrame().ip += 1
+		frame := vm.currentFrame()
+		err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpSetLocal:
+		// Get index of binding
+		localIndex := bytecode.ReadUint8(instructions[ip+1:])
+		vm.currentFrame().ip += 1
+		// Get current frame
+		frame := vm.currentFrame()
+		// Save the binding to the location on the stack
+		vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+	case bytecode.OpReturnNothing:
+		frame := vm.popFrame()
+		vm.stackPointer = frame.basePointer - 1 // Reset back to base pointer and also pop function
+		err := vm.push(Null)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpReturnValue:
+		returnValue := vm.pop() // Pop return value off of stack
+		frame := vm.popFrame()
+		vm.stackPointer = frame.basePointer - 1 // Reset back to base pointer and also pop function
+		err := vm.push(returnValue)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpCall:
+		// Get number of arguments to function
+		numArgs := bytecode.ReadUint8(instructions[ip+1:])
+		vm.currentFrame().ip += 1
+
+		err := vm.callFunction(int(numArgs))
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpTailCall:
+		numArgs := bytecode.ReadUint8(instructions[ip+1:])
+		vm.currentFrame().ip += 1
 
-		// Fetch
-		vm.currentFrame().ip++
-		ip = vm.currentFrame().ip
-		instructions = vm.currentFrame().Instructions()
-		op = bytecode.Opcode(instructions[ip])
+		err := vm.tailCallFunction(int(numArgs))
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpIndex:
+		index := vm.pop()
+		left := vm.pop()
 
-		if PRINT_VM {
-			def, _ := bytecode.Lookup(byte(op))
-			color.Cyan("Current opcode: %s", def.Name)
+		err := vm.executeIndex(left, index)
+		if err != nil {
+			return false, err
 		}
+	case bytecode.OpHash:
+		numElements := int(bytecode.ReadUint16(instructions[ip+1:]))
+		vm.currentFrame().ip += 2
 
-		// Decode & Execute
-		switch op {
-		case bytecode.OpGetBuiltin:
-			builtinIndex := bytecode.ReadUint8(instructions[ip+1:])
-			vm.currentFrame().ip += 1
-			definition := object.Builtins[builtinIndex]
-			err := vm.push(definition.Builtin)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpGetLocal:
-			localIndex := bytecode.ReadUint8(instructions[ip+1:])
-			vm.currentFrame().ip += 1
-			frame := vm.currentFrame()
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
-			if err != nil {
-				return err
-			}
-		case bytecode.OpSetLocal:
-			// Get index of binding
-			localIndex := bytecode.ReadUint8(instructions[ip+1:])
-			vm.currentFrame().ip += 1
-			// Get current frame
-			frame := vm.currentFrame()
-			// Save the binding to the location on the stack
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
-		case bytecode.OpReturnNothing:
-			frame := vm.popFrame()
-			vm.stackPointer = frame.basePointer - 1 // Reset back to base pointer and also pop function
-			err := vm.push(Null)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpReturnValue:
-			returnValue := vm.pop() // Pop return value off of stack
-			frame := vm.popFrame()
-			vm.stackPointer = frame.basePointer - 1 // Reset back to base pointer and also pop function
-			err := vm.push(returnValue)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpCall:
-			// Get number of arguments to function
-			numArgs := bytecode.ReadUint8(instructions[ip+1:])
-			vm.currentFrame().ip += 1
-
-			err := vm.callFunction(int(numArgs))
-			if err != nil {
-				return err
-			}
-		case bytecode.OpIndex:
-			index := vm.pop()
-			left := vm.pop()
+		if object.MaxLiteralSize > 0 && numElements/2 > object.MaxLiteralSize {
+			return false, fmt.Errorf("Hash literal too large: %d pairs (max %d)",
+				numElements/2, object.MaxLiteralSize)
+		}
 
-			err := vm.executeIndex(left, index)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpHash:
-			numElements := int(bytecode.ReadUint16(instructions[ip+1:]))
-			vm.currentFrame().ip += 2
+		hash, err := vm.buildHash(vm.stackPointer-numElements, vm.stackPointer)
+		if err != nil {
+			return false, err
+		}
+		vm.stackPointer -= numElements
 
-			hash, err := vm.buildHash(vm.stackPointer-numElements, vm.stackPointer)
-			if err != nil {
-				return err
-			}
-			vm.stackPointer -= numElements
+		err = vm.push(hash)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpArray:
+		numElements := int(bytecode.ReadUint16(instructions[ip+1:]))
+		vm.currentFrame().ip += 2
 
-			err = vm.push(hash)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpArray:
-			numElements := int(bytecode.ReadUint16(instructions[ip+1:]))
-			vm.currentFrame().ip += 2
+		if object.MaxLiteralSize > 0 && numElements > object.MaxLiteralSize {
+			return false, fmt.Errorf("Array literal too large: %d elements (max %d)",
+				numElements, object.MaxLiteralSize)
+		}
 
-			array := vm.buildArray(vm.stackPointer-numElements, vm.stackPointer)
-			vm.stackPointer -= numElements
+		array := vm.buildArray(vm.stackPointer-numElements, vm.stackPointer)
+		vm.stackPointer -= numElements
 
-			err := vm.push(array)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpGetGlobal:
-			globalIndex := bytecode.ReadUint16(instructions[ip+1:])
-			vm.currentFrame().ip += 2
+		err := vm.push(array)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpGetGlobal:
+		globalIndex := bytecode.ReadUint16(instructions[ip+1:])
+		vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
-			if err != nil {
-				return err
-			}
-		case bytecode.OpSetGlobal:
-			globalIndex := bytecode.ReadUint16(instructions[ip+1:])
-			vm.currentFrame().ip += 2
-			vm.globals[globalIndex] = vm.pop()
-		case bytecode.OpNull:
-			err := vm.push(Null)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpJumpNotTruthy:
-			position := int(bytecode.ReadUint16(instructions[ip+1:]))
-			// Skip over operand
-			vm.currentFrame().ip += 2
-
-			condition := vm.pop()
-			if !isTruthy(condition) {
-				vm.currentFrame().ip = position - 1
-			}
-		case bytecode.OpJump:
-			position := int(bytecode.ReadUint16(instructions[ip+1:]))
-			// -1 because loop increments ip
+		if int(globalIndex) >= len(vm.globals) {
+			return false, fmt.Errorf("Undefined global %d", globalIndex)
+		}
+
+		err := vm.push(vm.globals[globalIndex])
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpSetGlobal:
+		globalIndex := bytecode.ReadUint16(instructions[ip+1:])
+		vm.currentFrame().ip += 2
+		err := vm.setGlobal(int(globalIndex), vm.pop())
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpNull:
+		err := vm.push(Null)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpJumpNotTruthy:
+		position := int(bytecode.ReadUint16(instructions[ip+1:]))
+		// Skip over operand
+		vm.currentFrame().ip += 2
+
+		condition := vm.pop()
+		if !isTruthy(condition) {
 			vm.currentFrame().ip = position - 1
-		case bytecode.OpConstant:
-			constIndex := bytecode.ReadUint16(instructions[ip+1:])
-			// Skip over operand
-			vm.currentFrame().ip += 2
-
-			err := vm.push(vm.constants[constIndex])
-			if err != nil {
-				return err
-			}
-		case bytecode.OpAdd, bytecode.OpSub, bytecode.OpMul, bytecode.OpDiv:
-			err := vm.executeBinaryOperation(op)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpPop:
+		}
+	case bytecode.OpJumpTruthyOrPop, bytecode.OpJumpNotTruthyOrPop:
+		position := int(bytecode.ReadUint16(instructions[ip+1:]))
+		// Skip over operand
+		vm.currentFrame().ip += 2
+
+		// Jump with the deciding value left on the stack as the result
+		condition := vm.stack[vm.stackPointer-1]
+		if isTruthy(condition) == (op == bytecode.OpJumpTruthyOrPop) {
+			vm.currentFrame().ip = position - 1
+		} else {
 			vm.pop()
-		case bytecode.OpTrue:
-			err := vm.push(True)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpFalse:
-			err := vm.push(False)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpEqual, bytecode.OpNotEqual, bytecode.OpGreater:
-			err := vm.executeComparison(op)
-			if err != nil {
-				return err
-			}
-		case bytecode.OpBang:
-			err := vm.executeBang()
-			if err != nil {
-				return err
-			}
-		case bytecode.OpMinus:
-			err := vm.executeMinus()
-			if err != nil {
-				return err
-			}
 		}
+	case bytecode.OpEnterLoop:
+		frame := vm.currentFrame()
+		frame.loops = append(frame.loops, vm.stackPointer)
+	case bytecode.OpExitLoop:
+		frame := vm.currentFrame()
+		frame.loops = frame.loops[:len(frame.loops)-1]
+	case bytecode.OpUnwindLoop:
+		// A break or continue inside an expression leaves that expression's operands behind
+		frame := vm.currentFrame()
+		vm.stackPointer = frame.loops[len(frame.loops)-1]
+	case bytecode.OpJump:
+		position := int(bytecode.ReadUint16(instructions[ip+1:]))
+		// -1 because loop increments ip
+		vm.currentFrame().ip = position - 1
+	case bytecode.OpConstant:
+		constIndex := bytecode.ReadUint16(instructions[ip+1:])
+		// Skip over operand
+		vm.currentFrame().ip += 2
+
+		err := vm.push(vm.constants[constIndex])
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpAdd, bytecode.OpSub, bytecode.OpMul, bytecode.OpDiv, bytecode.OpMod:
+		err := vm.executeBinaryOperation(op)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpPop:
+		vm.pop()
+	case bytecode.OpTrue:
+		err := vm.push(True)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpFalse:
+		err := vm.push(False)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpEqual, bytecode.OpNotEqual, bytecode.OpGreater, bytecode.OpGreaterEqual,
+		bytecode.OpLess, bytecode.OpLessEqual:
+		err := vm.executeComparison(op)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpIn:
+		container := vm.pop()
+		item := vm.pop()
+
+		err := vm.executeIn(item, container)
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpBang:
+		err := vm.executeBang()
+		if err != nil {
+			return false, err
+		}
+	case bytecode.OpMinus:
+		err := vm.executeMinus()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return vm.finished(), nil
+}
+
+// Helper method to check if there are no instructions left to execute
+func (vm *VM) finished() bool {
+	return vm.currentFrame().ip >= len(vm.currentFrame().Instructions())-1
+}
+
+// Offset of the next instruction in the current frame
+func (vm *VM) InstructionPointer() int {
+	return vm.currentFrame().ip + 1
+}
+
+// Current contents of the stack (bottom first)
+func (vm *VM) Stack() []object.Object {
+	return vm.stack[:vm.stackPointer]
+}
+
+// Helper method to set a global, growing globals as needed
+func (vm *VM) setGlobal(index int, o object.Object) error {
+	if index >= vm.globalSize {
+		return fmt.Errorf("Too many globals")
 	}
 
+	if index >= len(vm.globals) {
+		vm.globals = append(vm.globals, make([]object.Object, index+1-len(vm.globals))...)
+	}
+
+	vm.globals[index] = o
 	return nil
 }
 
@@ -249,22 +423,28 @@ func (vm *VM) Run() error {
 func (vm *VM) callFunction(numArgs int) error {
 	fn := vm.stack[vm.stackPointer-1-numArgs]
 	switch fn := fn.(type) {
-	case *object.CompiledFunction:
-		if numArgs != fn.NumParameters {
+	case *object.Closure:
+		if numArgs != fn.Fn.NumParameters {
 			return fmt.Errorf(
 				"Wrong number of arguments. Expected=%d, Actual=%d",
-				fn.NumParameters,
+				fn.Fn.NumParameters,
 				numArgs)
 		}
 		// basePointer is vm.stackPointer - numArgs
 		frame := BuildFrame(fn, vm.stackPointer-numArgs)
+		if vm.framesIndex >= len(vm.frames) || frame.basePointer+fn.Fn.NumLocals >= len(vm.stack) {
+			return fmt.Errorf("Stack overflow")
+		}
 		vm.pushFrame(frame)
-		vm.stackPointer = frame.basePointer + fn.NumLocals
+		vm.stackPointer = frame.basePointer + fn.Fn.NumLocals
 		return nil
 	case *object.BuiltIn:
 		args := vm.stack[vm.stackPointer-numArgs : vm.stackPointer]
-		result := fn.Function(args...)
+		result := fn.Call(args...)
 		vm.stackPointer = vm.stackPointer - numArgs - 1
+		if apply, ok := result.(*object.Apply); ok {
+			return vm.callApplied(apply)
+		}
 		if result != nil {
 			vm.push(result)
 		} else {
@@ -277,10 +457,84 @@ func (vm *VM) callFunction(numArgs int) error {
 
 }
 
+// Helper method for apply: push the function and its arguments, then call it like OpCall would
+// If the builtin needs the result (apply.Then), the call is run to completion right away
+func (vm *VM) callApplied(apply *object.Apply) error {
+	err := vm.push(apply.Function)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range apply.Arguments {
+		err := vm.push(arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	framesIndex := vm.framesIndex
+	err = vm.callFunction(len(apply.Arguments))
+	if err != nil || apply.Then == nil {
+		return err
+	}
+
+	for vm.framesIndex > framesIndex {
+		_, err := vm.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	result := apply.Then(vm.pop())
+	if result == nil {
+		result = Null
+	}
+	return vm.push(result)
+}
+
+// Helper method for calls whose result is returned right away: the current frame is reused for the callee
+// Anything else (e.g. builtins, wrong number of arguments, calls outside functions) is a normal call
+func (vm *VM) tailCallFunction(numArgs int) error {
+	cl, ok := vm.stack[vm.stackPointer-1-numArgs].(*object.Closure)
+	if !ok || numArgs != cl.Fn.NumParameters || vm.framesIndex <= 1 {
+		return vm.callFunction(numArgs)
+	}
+
+	frame := vm.currentFrame()
+	if frame.basePointer+cl.Fn.NumLocals >= len(vm.stack) {
+		return fmt.Errorf("Stack overflow")
+	}
+
+	// Move callee and arguments to where the current function and its arguments are
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.stackPointer-1-numArgs:vm.stackPointer])
+
+	frame.cl = cl
+	frame.ip = -1
+	frame.loops = frame.loops[:0]
+	vm.stackPointer = frame.basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+// Helper method for closure: wrap a compiled function with the free variables on top of the stack
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("Not a function: %+v", vm.constants[constIndex])
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.stackPointer-numFree:vm.stackPointer])
+	vm.stackPointer = vm.stackPointer - numFree
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
 // Helper method for index
 func (vm *VM) executeIndex(left, index object.Object) error {
 	if left.Type() == object.ARRAY_OBJECT && index.Type() == object.INTEGER_OBJECT {
 		return vm.executeArrayIndex(left, index)
+	} else if left.Type() == object.STRING_OBJECT && index.Type() == object.INTEGER_OBJECT {
+		return vm.push(left.(*object.String).Index(index.(*object.Integer).Value))
 	} else if left.Type() == object.HASH_OBJECT {
 		return vm.executeHashIndex(left, index)
 	} else {
@@ -290,20 +544,13 @@ func (vm *VM) executeIndex(left, index object.Object) error {
 
 // Helper method for array index
 func (vm *VM) executeArrayIndex(array, index object.Object) error {
-	arrayObject := array.(*object.Array)
-	i := index.(*object.Integer).Value
-
-	if i < 0 || i > int64(len(arrayObject.Elements)-1) {
-		return vm.push(Null)
-	} else {
-		return vm.push(arrayObject.Elements[i])
-	}
+	return vm.push(array.(*object.Array).Index(index.(*object.Integer).Value))
 }
 
 // Helper method for hash index
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashKey(index)
 	if !ok {
 		return fmt.Errorf("Unusable as hash key")
 	}
@@ -326,9 +573,9 @@ func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 		pair := object.HashPair{Key: key, Value: value}
 
 		// Check if key is hashable
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashKey(key)
 		if !ok {
-			return nil, fmt.Errorf("Key is unhashable")
+			return nil, fmt.Errorf("Unusable as hash key")
 		}
 
 		// Hash the key
@@ -364,11 +611,14 @@ func isTruthy(obj object.Object) bool {
 func (vm *VM) executeMinus() error {
 	value := vm.pop()
 
-	if value.Type() != object.INTEGER_OBJECT {
+	switch value := value.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -value.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -value.Value})
+	default:
 		return fmt.Errorf("Unsupported type: %s", value.Type())
 	}
-
-	return vm.push(&object.Integer{Value: -value.(*object.Integer).Value})
 }
 
 // Helper method to execute !
@@ -387,26 +637,51 @@ func (vm *VM) executeBang() error {
 	}
 }
 
-// Helper method to execute !=, >, ==
+// Helper method to execute ==, !=, >, >=, <, <=
 func (vm *VM) executeComparison(op bytecode.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if left.Type() == object.INTEGER_OBJECT || right.Type() == object.INTEGER_OBJECT {
+	if object.IsFloatOperation(left, right) {
+		return vm.executeFloatComparison(object.ToFloat(left), op, object.ToFloat(right))
+	}
+
+	if left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT {
 		return vm.executeIntegerComparison(left, op, right)
 	}
 
+	// Strings are compared by value and ordered byte by byte
+	if left.Type() == object.STRING_OBJECT && right.Type() == object.STRING_OBJECT {
+		leftValue := left.(*object.String).Value
+		rightValue := right.(*object.String).Value
+
+		switch op {
+		case bytecode.OpEqual:
+			return vm.push(toBooleanObject(leftValue == rightValue))
+		case bytecode.OpNotEqual:
+			return vm.push(toBooleanObject(leftValue != rightValue))
+		case bytecode.OpGreater:
+			return vm.push(toBooleanObject(leftValue > rightValue))
+		case bytecode.OpGreaterEqual:
+			return vm.push(toBooleanObject(leftValue >= rightValue))
+		case bytecode.OpLess:
+			return vm.push(toBooleanObject(leftValue < rightValue))
+		case bytecode.OpLessEqual:
+			return vm.push(toBooleanObject(leftValue <= rightValue))
+		}
+	}
+
 	switch op {
 	case bytecode.OpEqual:
 		return vm.push(toBooleanObject(right == left))
 	case bytecode.OpNotEqual:
 		return vm.push(toBooleanObject(right != left))
 	default:
-		return fmt.Errorf("Unknown operator: %s %d %s", left.Type(), op, right.Type())
+		return fmt.Errorf("Unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
 	}
 }
 
-// Helper method to execute !=, >, == for integers
+// Helper method to execute ==, !=, >, >=, <, <= for integers
 func (vm *VM) executeIntegerComparison(
 	left object.Object, op bytecode.Opcode, right object.Object) error {
 	leftValue := left.(*object.Integer).Value
@@ -419,11 +694,52 @@ func (vm *VM) executeIntegerComparison(
 		return vm.push(toBooleanObject(leftValue != rightValue))
 	case bytecode.OpGreater:
 		return vm.push(toBooleanObject(leftValue > rightValue))
+	case bytecode.OpGreaterEqual:
+		return vm.push(toBooleanObject(leftValue >= rightValue))
+	case bytecode.OpLess:
+		return vm.push(toBooleanObject(leftValue < rightValue))
+	case bytecode.OpLessEqual:
+		return vm.push(toBooleanObject(leftValue <= rightValue))
 	default:
-		return fmt.Errorf("Unknown operator: %d", op)
+		return fmt.Errorf("Unknown operator: %s", operators[op])
 	}
 }
 
+// Helper method to execute ==, !=, >, >=, <, <= for floats
+func (vm *VM) executeFloatComparison(left float64, op bytecode.Opcode, right float64) error {
+	switch op {
+	case bytecode.OpEqual:
+		return vm.push(toBooleanObject(left == right))
+	case bytecode.OpNotEqual:
+		return vm.push(toBooleanObject(left != right))
+	case bytecode.OpGreater:
+		return vm.push(toBooleanObject(left > right))
+	case bytecode.OpGreaterEqual:
+		return vm.push(toBooleanObject(left >= right))
+	case bytecode.OpLess:
+		return vm.push(toBooleanObject(left < right))
+	case bytecode.OpLessEqual:
+		return vm.push(toBooleanObject(left <= right))
+	default:
+		return fmt.Errorf("Unknown operator: %s", operators[op])
+	}
+}
+
+// Operators that arithmetic and comparison opcodes are compiled from (for error messages)
+var operators = map[bytecode.Opcode]string{
+	bytecode.OpAdd:          "+",
+	bytecode.OpSub:          "-",
+	bytecode.OpMul:          "*",
+	bytecode.OpDiv:          "/",
+	bytecode.OpMod:          "%",
+	bytecode.OpEqual:        "==",
+	bytecode.OpNotEqual:     "!=",
+	bytecode.OpGreater:      ">",
+	bytecode.OpGreaterEqual: ">=",
+	bytecode.OpLess:         "<",
+	bytecode.OpLessEqual:    "<=",
+}
+
 // Helper method to convert bool to boolean objects
 func toBooleanObject(input bool) *object.Boolean {
 	if input {
@@ -451,25 +767,113 @@ func (vm *VM) executeBinaryOperation(op bytecode.Opcode) error {
 			result = leftValue - rightValue
 		case bytecode.OpMul:
 			result = leftValue * rightValue
-		case bytecode.OpDiv:
-			result = leftValue / rightValue
+		case bytecode.OpDiv, bytecode.OpMod:
+			// Same message as the evaluator
+			if rightValue == 0 {
+				return fmt.Errorf("division by zero")
+			}
+
+			if op == bytecode.OpDiv {
+				result = leftValue / rightValue
+			} else {
+				result = leftValue % rightValue
+			}
 		default:
-			return fmt.Errorf("Unsupported operator for integer: %s", op)
+			return fmt.Errorf("Unsupported operator for integer: %s", operators[op])
 		}
 
 		return vm.push(&object.Integer{Value: result})
+	} else if object.IsFloatOperation(left, right) {
+		return vm.executeFloatOperation(object.ToFloat(left), op, object.ToFloat(right))
 	} else if left.Type() == object.STRING_OBJECT && right.Type() == object.STRING_OBJECT {
 		if op != bytecode.OpAdd {
-			return fmt.Errorf("Unsupported operator for string: %s", op)
+			return fmt.Errorf("Unsupported operator for string: %s", operators[op])
 		}
 
 		leftValue := left.(*object.String).Value
 		rightValue := right.(*object.String).Value
 
 		return vm.push(&object.String{Value: leftValue + rightValue})
+	} else if op == bytecode.OpMul && left.Type() == object.STRING_OBJECT && right.Type() == object.INTEGER_OBJECT {
+		return vm.executeStringRepeat(left.(*object.String).Value, right.(*object.Integer).Value)
+	} else if op == bytecode.OpMul && left.Type() == object.INTEGER_OBJECT && right.Type() == object.STRING_OBJECT {
+		return vm.executeStringRepeat(right.(*object.String).Value, left.(*object.Integer).Value)
+	} else if op == bytecode.OpMul && left.Type() == object.ARRAY_OBJECT && right.Type() == object.INTEGER_OBJECT {
+		return vm.executeArrayRepeat(left.(*object.Array).Elements, right.(*object.Integer).Value)
+	} else if op == bytecode.OpMul && left.Type() == object.INTEGER_OBJECT && right.Type() == object.ARRAY_OBJECT {
+		return vm.executeArrayRepeat(right.(*object.Array).Elements, left.(*object.Integer).Value)
 	} else {
-		return fmt.Errorf("Unsupported types for binary operation: %s %s", left.Type(), right.Type())
+		return fmt.Errorf("Unsupported types for binary operation: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+// Execute +,-,*,/ for floats (integer operands are promoted)
+func (vm *VM) executeFloatOperation(left float64, op bytecode.Opcode, right float64) error {
+	var result float64
+
+	switch op {
+	case bytecode.OpAdd:
+		result = left + right
+	case bytecode.OpSub:
+		result = left - right
+	case bytecode.OpMul:
+		result = left * right
+	case bytecode.OpDiv:
+		// IEEE semantics, same as the evaluator: x / 0.0 is +Inf, -Inf or NaN
+		result = left / right
+	default:
+		return fmt.Errorf("Unsupported operator for float: %s", operators[op])
 	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
+// Execute membership e.g. "1 in [1, 2]"
+func (vm *VM) executeIn(item object.Object, container object.Object) error {
+	found, ok := object.Contains(container, item)
+	if !ok {
+		return fmt.Errorf("Unsupported operands: %s in %s", item.Type(), container.Type())
+	}
+
+	return vm.push(toBooleanObject(found))
+}
+
+// Execute string * integer (e.g. "ab" * 3 is "ababab")
+func (vm *VM) executeStringRepeat(str string, count int64) error {
+	if count < 0 {
+		return fmt.Errorf("Negative repeat count: %d", count)
+	}
+	if object.RepeatTooLarge(len(str), count) {
+		return fmt.Errorf("Repeat count too large: %d", count)
+	}
+
+	return vm.push(&object.String{Value: strings.Repeat(str, int(count))})
+}
+
+// Execute array * integer (e.g. [0] * 3 is [0, 0, 0])
+func (vm *VM) executeArrayRepeat(elements []object.Object, count int64) error {
+	if count < 0 {
+		return fmt.Errorf("Negative repeat count: %d", count)
+	}
+
+	if len(elements) == 0 {
+		return vm.push(&object.Array{Elements: []object.Object{}})
+	}
+	if object.RepeatTooLarge(len(elements), count) {
+		return fmt.Errorf("Repeat count too large: %d", count)
+	}
+
+	size := len(elements) * int(count)
+	if object.MaxLiteralSize > 0 && size > object.MaxLiteralSize {
+		return fmt.Errorf("Array too large: %d elements (max %d)", size, object.MaxLiteralSize)
+	}
+
+	repeated := make([]object.Object, 0, size)
+	for i := int64(0); i < count; i++ {
+		repeated = append(repeated, elements...)
+	}
+
+	return vm.push(&object.Array{Elements: repeated})
 }
 
 // Get last popped element (for debugging)
@@ -477,9 +881,26 @@ func (vm *VM) LastPopped() object.Object {
 	return vm.stack[vm.stackPointer]
 }
 
+// Get result of a program that has been run (the last popped element)
+// Errors if the program hasn't finished or left values on the stack
+func (vm *VM) Result() (object.Object, error) {
+	if vm.framesIndex != 1 || !vm.finished() {
+		return nil, fmt.Errorf("Program has not finished running")
+	}
+	if vm.stackPointer != 0 {
+		return nil, fmt.Errorf("Program left %d values on the stack", vm.stackPointer)
+	}
+
+	result := vm.LastPopped()
+	if result == nil {
+		return Null, nil
+	}
+	return result, nil
+}
+
 // Push to stack
 func (vm *VM) push(o object.Object) error {
-	if vm.stackPointer >= stackCapacity {
+	if vm.stackPointer >= len(vm.stack) {
 		return fmt.Errorf("Stack overflow")
 	}
 
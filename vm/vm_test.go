@@ -1,12 +1,16 @@
 package vm
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
 	"go_interpreter/ast"
+	"go_interpreter/bytecode"
 	"go_interpreter/compiler"
+	"go_interpreter/evaluator"
 	"go_interpreter/lexer"
 	"go_interpreter/object"
 	"go_interpreter/parser"
+	"os"
 	"testing"
 )
 
@@ -29,9 +33,227 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-5", -5},
 		{"-3 + 9", 6},
 		{"(15/-3) + 7", 2},
+		{"10 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	testVM(t, tests)
+
+	testVMError(t, "10 % 0", "division by zero")
+	testVMError(t, "5 / 0", "division by zero")
+	testVMError(t, "let zero = 1 - 1; let f = fn(x) { x / zero }; f(5); 1", "division by zero")
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14", "3.14"},
+		{"-1.5", "-1.5"},
+		{"1.5 + 2", "3.5"},
+		{"2 + 3.5", "5.5"},
+		{"0.5 * 4", "2.0"},
+		{"7 / 2.0", "3.5"},
+		{"1.0 - 0.25", "0.75"},
+		{"1.5 < 2", "true"},
+		{"2 > 1.5", "true"},
+		{"2 == 2.0", "true"},
+		{"2.5 != 2.5", "false"},
+		{"let half = fn(x) { x / 2.0 }; half(5)", "2.5"},
+	}
+
+	for _, test := range tests {
+		testVMInspect(t, test.input, test.expected)
+	}
+
+	testVMError(t, "1.5 % 1", "Unsupported operator for float: %")
+}
+
+func TestNaNInf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.0 / 0.0", "+Inf"},
+		{"-1.0 / 0", "-Inf"},
+		{"1 / 0.0", "+Inf"},
+		{"0.0 / 0.0", "NaN"},
+		{"1.0 / 0.0 > 1000000", "true"},
+		{"let nan = 0.0 / 0.0; nan == nan", "false"},
+		{"let nan = 0.0 / 0.0; nan != nan", "true"},
+		{"let nan = 0.0 / 0.0; nan < 1", "false"},
+		{"let nan = 0.0 / 0.0; nan >= 1", "false"},
+		{"let inf = 1.0 / 0.0; inf == inf", "true"},
+		{"let inf = 1.0 / 0.0; inf - inf", "NaN"},
+		{"let nan = 0.0 / 0.0; nan in [nan]", "false"},
+		{`{0.0: "a"}[-0.0]`, "a"},
+		{"1 in [1.0]", "true"},
+		{"delete({1: 2}, 0.0 / 0.0)", "ERROR: unusable as hash key: FLOAT"},
+		{"delete({0.0: 1, 2: 3}, -0.0)", "{2: 3}"},
+		{"-0.0 in {0.0: 1}", "true"},
+	}
+
+	for _, test := range tests {
+		testVMInspect(t, test.input, test.expected)
+	}
+
+	testVMError(t, "1 / 0", "division by zero")
+	testVMError(t, "{0.0 / 0.0: 1}", "Unusable as hash key")
+	testVMError(t, "(0.0 / 0.0) in {1: 2}", "Unsupported operands: FLOAT in HASH")
+}
+
+func TestWhile(t *testing.T) {
+	tests := []testCase{
+		{"let i = 0; let sum = 0; while (i < 5) { let i = i + 1; let sum = sum + i; } sum", 15},
+		{"let i = 0; while (i < 3) { let i = i + 1; } i", 3},
+		{"let i = 10; while (i < 3) { let i = i + 1; } i", 10},
+		{"let f = fn() { let i = 0; while (true) { if (i == 4) { return i * 10; } let i = i + 1; } }; f()", 40},
+		{"let f = fn(n) { let total = 0; while (n > 0) { let total = total + n; let n = n - 1; } total }; f(4)", 10},
+	}
+
+	testVM(t, tests)
+
+	testVMError(t, "let i = 0; while (true) { let i = i + 1; i + true; }",
+		"Unsupported types for binary operation: INTEGER + BOOLEAN")
+}
+
+func TestConstParity(t *testing.T) {
+	inputs := []string{
+		"const one = 1; let two = one + one; one + two",
+		// The same const runs on every iteration of a loop
+		"let i = 0; while (i < 2) { const y = 1; let i = i + 1; } i",
+		"let i = 0; while (i < 3) { const y = i * 2; let i = i + 1; } y",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestLoopShadowParity(t *testing.T) {
+	inputs := []string{
+		// The let in the body makes a local the condition sees from the second iteration on
+		"let i = 0; let f = fn() { while (i < 3) { let i = i + 1; } i }; f()",
+		"let i = 0; let f = fn() { while (i < 3) { let i = i + 1; } i }; f(); i",
+		"let i = 5; let f = fn() { while (i < 3) { let i = i + 1; } i }; f()",
+		"let f = fn(n) { let g = fn() { let total = 0; while (n > 0) { let total = total + n; let n = n - 1; } total }; g() }; f(4)",
+		"let i = 0; let f = fn() { while (true) { if (i > 2) { break; }; let i = i + 1; } i }; f()",
+		"let i = 0; let f = fn() { while (true) { if (i > 2) { break; } else { let i = i + 1; } } i }; f()",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestReturnInExpressionParity(t *testing.T) {
+	inputs := []string{
+		"let f = fn(x) { x + 1 }; let g = fn() { f(if (true) { return 2; }) + 10 }; g() + 100",
+		"let g = fn() { [1, if (true) { return 4; }][0] + 1 }; g()",
+		"let g = fn(n) { let x = n * if (n > 2) { return 0; } else { 2 }; x }; [g(1), g(3)]",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestLoopJumpInExpressionParity(t *testing.T) {
+	inputs := []string{
+		"let i = 0; while (true) { let i = i + 1; let x = if (i == 3) { break; } else { i }; } i",
+		"let i = 0; let sum = 0; while (i < 5) { let i = i + 1; let x = if (i == 2) { continue; } else { i }; let sum = sum + x; } sum",
+		"let i = 0; while (true) { let i = i + 1; i + if (i == 3) { break; } else { 0 }; } i",
+		"let i = 0; while (true) { let i = i + 1; [1, 2, if (i == 3) { break; } else { 3 }]; } i",
+		"let i = 0; let n = 0; while (i < 5) { let i = i + 1; let n = n + len([1, 2, if (i % 2 == 0) { continue; } else { 3 }]); } n",
+		"let f = fn(a, b) { a + b }; let i = 0; while (true) { let i = i + 1; f(i, if (i == 3) { break; } else { 0 }); } i",
+		"let f = fn() { let i = 0; while (true) { let i = i + 1; let x = [i, if (i == 3) { break; } else { i }]; } i }; f()",
+		"let i = 0; while (i < 3) { if (true) { } let i = i + 1; } i",
+		// Values left behind by every continue would overflow the stack
+		"let i = 0; while (i < 5000) { let i = i + 1; [1, 2, if (true) { continue; }]; } i",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	tests := []testCase{
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } } i", 3},
+		{"let i = 0; let sum = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue; } let sum = sum + i; } sum", 13},
+		// break only exits the innermost loop
+		{`let i = 0; let count = 0;
+		  while (i < 3) {
+		    let i = i + 1;
+		    let j = 0;
+		    while (true) { let j = j + 1; if (j > i) { break; } let count = count + 1; }
+		  }
+		  count`, 6},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i > 2) { break; } } i }; f()", 3},
+	}
+
+	testVM(t, tests)
+}
+
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       string
+		expectedOutput string
+	}{
+		{`false && print("right")`, "false", ""},
+		{`true || print("right")`, "true", ""},
+		{`let f = fn() { print("left"); false }; f() && print("right")`, "false", "left\n"},
+		{`true && print("right")`, "null", "right\n"},
+		{`false || print("right")`, "null", "right\n"},
+		// Operands are returned, not converted to booleans
+		{`1 && "b"`, "b", ""},
+		{`0 || "b"`, "0", ""},
+		{`if (false) { 1 } || 2`, "2", ""},
+		{"1 > 2 || 3 > 2 && 2 > 1", "true", ""},
+		{"let x = false || 5; x + 1", "6", ""},
+	}
+
+	defer func() { object.Output = os.Stdout }()
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		object.Output = &out
+
+		testVMInspect(t, test.input, test.expected)
+		assert.Equal(t, test.expectedOutput, out.String(), test.input)
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	defer func() { object.Output = os.Stdout }()
+
+	var out bytes.Buffer
+	object.Output = &out
+
+	input := `let greet = fn(name, n) { printf("hi %s x%d", name, n); println("!", n * 2) }; greet("bob", 3); format("%s/%s", 1, [2])`
+	testVMInspect(t, input, "1/[2]")
+	assert.Equal(t, "hi bob x3! 6\n", out.String())
+}
+
+func TestMemoize(t *testing.T) {
+	defer func() { object.Output = os.Stdout }()
+
+	var out bytes.Buffer
+	object.Output = &out
+
+	input := `let double = memoize(fn(x) { print(x); x * 2 }); [double(2), double(2), double(3), double(2)]`
+	testVMInspect(t, input, "[4, 4, 6, 4]")
+	assert.Equal(t, "2\n3\n", out.String())
+
+	testVM(t, []testCase{
+		{"let fib = memoize(fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }); fib(80)", 23416728348467685},
+		{"let f = fn(k) { memoize(fn(n) { n * k }) }; let g = f(3); g(2) + g(2)", 12},
+		{"let count = memoize(fn(n) { if (n == 0) { 0 } else { count(n - 1) + 1 } }); count(5)", 5},
+		{"let f = memoize(fn(n) { n + 1 }); let g = fn(n) { f(n) }; g(1) + g(1)", 4},
+	})
 }
 
 func TestBoolean(t *testing.T) {
@@ -48,9 +270,36 @@ func TestBoolean(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!(if (false) { 5; })", true},
+		{"!true == false", true},
+		{"true != !false", false},
+		{"let x = false; !x == true", true},
+		{"5 <= 5", true},
+		{"5 >= 5", true},
+		{"4 <= 5", true},
+		{"4 >= 5", false},
+		{"1 + 1 <= 2 == true", true},
+		{"1.5 <= 1.5", true},
+		{"2 >= 2.5", false},
+		{`"a" < "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"abc" >= "abc"`, true},
+		{`"abc" > "ab"`, true},
+		{`"abc" == "abc"`, true},
+		{`"abc" != "abc"`, false},
+		{`"abc" == "abd"`, false},
+		{`"abc" != "ab"`, true},
+		{`let s = "ab"; s + "c" == "abc"`, true},
+		{`"" == ""`, true},
 	}
 
 	testVM(t, tests)
+
+	// Errors name the operator, with the operands in source order
+	testVMError(t, `1 < "a"`, "Unknown operator: INTEGER < STRING")
+	testVMError(t, `"a" <= 1`, "Unknown operator: STRING <= INTEGER")
+	testVMError(t, `[1] > 2`, "Unknown operator: ARRAY > INTEGER")
+	testVMError(t, "true >= false", "Unknown operator: BOOLEAN >= BOOLEAN")
+	testVMError(t, `"a" * "b"`, "Unsupported operator for string: *")
 }
 
 func TestConditional(t *testing.T) {
@@ -71,6 +320,18 @@ func TestGlobalLet(t *testing.T) {
 		{"let one = 1; one", 1},
 		{"let one = 1; let two = 2; one + two", 3},
 		{"let one = 1; let two = one + one; one + two", 3},
+		{"const one = 1; let two = one + one; one + two", 3},
+		{"let one = 1; let one = one + 1; one", 2},
+	}
+
+	testVM(t, tests)
+}
+
+func TestMultiLet(t *testing.T) {
+	tests := []testCase{
+		{"let a = 1, b = 2, c = 3; [a, b, c]", []int{1, 2, 3}},
+		{"let a = 2, b = a * 10; b", 20},
+		{"let f = fn() { let x = 1, y = x + 1; y }; f()", 2},
 	}
 
 	testVM(t, tests)
@@ -85,6 +346,24 @@ func TestString(t *testing.T) {
 	testVM(t, tests)
 }
 
+func TestStringRepeat(t *testing.T) {
+	tests := []testCase{
+		{`"ab" * 3`, "ababab"},
+		{`3 * "ab"`, "ababab"},
+		{`"ab" * 0`, ""},
+		{`0 * "ab"`, ""},
+		{`let s = "-"; s * (1 + 1)`, "--"},
+		{`"" * 9223372036854775807`, ""},
+	}
+
+	testVM(t, tests)
+
+	testVMError(t, `"ab" * -1`, "Negative repeat count: -1")
+	testVMError(t, `-2 * "ab"`, "Negative repeat count: -2")
+	testVMError(t, `"ab" * 4611686018427387904`, "Repeat count too large: 4611686018427387904")
+	testVMError(t, `"ab" - 1`, "Unsupported types for binary operation: STRING - INTEGER")
+}
+
 func TestArray(t *testing.T) {
 	tests := []testCase{
 		{"[]", []int{}},
@@ -95,6 +374,73 @@ func TestArray(t *testing.T) {
 	testVM(t, tests)
 }
 
+func TestArrayRepeat(t *testing.T) {
+	tests := []testCase{
+		{"[0] * 3", []int{0, 0, 0}},
+		{"2 * [1, 2]", []int{1, 2, 1, 2}},
+		{"[1, 2] * 0", []int{}},
+		{"[] * 5", []int{}},
+		{"let a = [1]; let b = a * 2; a", []int{1}},
+		{"[] * 9223372036854775807", []int{}},
+	}
+
+	testVM(t, tests)
+
+	testVMError(t, "[0] * -1", "Negative repeat count: -1")
+	testVMError(t, "[1] * 9223372036854775807", "Repeat count too large: 9223372036854775807")
+	testVMError(t, "[1, 2] * 4611686018427387904", "Repeat count too large: 4611686018427387904")
+
+	object.MaxLiteralSize = 4
+	defer func() { object.MaxLiteralSize = 0 }()
+	testVM(t, []testCase{{"[1, 2] * 2", []int{1, 2, 1, 2}}})
+	testVMError(t, "[1, 2] * 3", "Array too large: 6 elements (max 4)")
+}
+
+func TestIn(t *testing.T) {
+	tests := []testCase{
+		{"2 in [1, 2, 3]", true},
+		{"4 in [1, 2, 3]", false},
+		{`"b" in ["a", "b"]`, true},
+		{"[1] in [[1], [2]]", true},
+		{`"a" in {"a": 1}`, true},
+		{`"b" in {"a": 1}`, false},
+		{"1 in {1: 2}", true},
+		{`"ell" in "hello"`, true},
+		{`"xyz" in "hello"`, false},
+		{"1 + 1 in [2]", true},
+	}
+
+	testVM(t, tests)
+
+	testVMError(t, "1 in 2", "Unsupported operands: INTEGER in INTEGER")
+	testVMError(t, `1 in "1"`, "Unsupported operands: INTEGER in STRING")
+}
+
+func TestNotIn(t *testing.T) {
+	tests := []testCase{
+		{"2 not in [1, 2, 3]", false},
+		{"4 not in [1, 2, 3]", true},
+		{`"a" not in {"a": 1}`, false},
+		{`"b" not in {"a": 1}`, true},
+		{`"ell" not in "hello"`, false},
+		{`"xyz" not in "hello"`, true},
+	}
+
+	testVM(t, tests)
+
+	testVMError(t, "1 not in 2", "Unsupported operands: INTEGER in INTEGER")
+}
+
+func TestPipe(t *testing.T) {
+	tests := []testCase{
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double |> inc", 11},
+		{`"four" |> len`, 4},
+		{"1 + 2 |> fn(x) => x * 10", 30},
+	}
+
+	testVM(t, tests)
+}
+
 func TestHash(t *testing.T) {
 	tests := []testCase{
 		{
@@ -110,6 +456,27 @@ func TestHash(t *testing.T) {
 	}
 
 	testVM(t, tests)
+
+	testVMError(t, "{[1]: 2}", "Unusable as hash key")
+	testVMError(t, `{"a": 1}[fn(x) { x }]`, "Unusable as hash key")
+}
+
+func TestHashParity(t *testing.T) {
+	inputs := []string{
+		`{"a": 1}["a"]`,
+		`{"a": 1}["b"]`,
+		`{}["a"]`,
+		"{1: 2, 3: 4}[3]",
+		"{true: \"yes\", false: \"no\"}[1 > 2]",
+		`{"one": 1, "two": 2}["o" + "ne"] + {"one": 1, "two": 2}["two"]`,
+		`{"inner": {"x": [1, 2]}}["inner"]["x"][1]`,
+		`let key = "k"; let h = {key: 5}; h["k"] * 2`,
+		`let get = fn(h, k) { h[k] }; get({"a": fn(x) { x + 1 }}, "a")(1)`,
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
 }
 
 func TestIndex(t *testing.T) {
@@ -118,11 +485,47 @@ func TestIndex(t *testing.T) {
 		{"[1,2,3][10-9]", 2},
 		{"[[1,1,1]][0][0]", 1},
 		{"[1,2,3][9*11]", Null},
+		{"[1,2,3][3]", Null},
+		{"[1,2,3][-1]", 3},
+		{"[1,2,3][-3]", 1},
+		{"[1,2,3][-4]", Null},
+		{"[][0]", Null},
 	}
 
 	testVM(t, tests)
 }
 
+func TestArrayParity(t *testing.T) {
+	inputs := []string{
+		"[1, 2, 3][1]",
+		"[1, 2, 3][0 - 1]",
+		"[1, 2, 3][-1]",
+		"[1, 2, 3][-3]",
+		"[1, 2, 3][-4]",
+		"let a = [1, 2, 3]; a[-len(a)]",
+		"[1, 2, 3][3]",
+		"[1, 2, 3][99]",
+		"[][0]",
+		"[[1, 2], [3, [4, 5]]]",
+		"[[1, 2], [3, [4, 5]]][1][1][0]",
+		"[[1, 2], [3]][1][5]",
+		"let a = [1 + 1, \"two\", true, [3]]; [a[0], a[1], a[2], a[3][0]]",
+		"let first = fn(arr) { arr[0] }; first([fn(x) { x * 2 }])(21)",
+		"let i = 2; [10, 20, 30][i - 1]",
+		`"hello"[0]`,
+		`"hello"[4]`,
+		`let s = "hello"; s[len(s) - 1]`,
+		`"hello"[5]`,
+		`"hello"[-1]`,
+		`""[0]`,
+		`"héllo"[1]`,
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
 func TestCallFunction(t *testing.T) {
 	tests := []testCase{
 		{
@@ -137,6 +540,10 @@ func TestCallFunction(t *testing.T) {
 			"let foo = fn() {}; foo();",
 			Null,
 		},
+		{
+			"fn(){}()",
+			Null,
+		},
 		{
 			"let foo = fn() {1;}; let bar = fn() {foo;}; bar()();",
 			1,
@@ -154,13 +561,427 @@ func TestCallFunction(t *testing.T) {
 	testVM(t, tests)
 }
 
+func TestFunctionParity(t *testing.T) {
+	inputs := []string{
+		"let add = fn(a, b) { a + b }; add(2, 3)",
+		"let add = fn(a, b) { a + b }; add(add(1, 2), add(3, 4))",
+		"let early = fn(x) { if (x > 2) { return \"big\"; } \"small\" }; [early(1), early(5)]",
+		"let noop = fn() {}; noop()",
+		"let twice = fn(f, x) { f(f(x)) }; twice(fn(x) { x * 3 }, 2)",
+		"let g = 10; let addG = fn(x) { let y = x + g; y }; addG(5)",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)",
+		"let identity = fn(x) { x }; identity(fn(a) { a })(\"same\")",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestArrowFunction(t *testing.T) {
+	tests := []testCase{
+		{"let f = fn(x) => x * 2; f(4);", 8},
+		{"let f = fn(x) { return x * 2 }; f(4);", 8},
+		{"(fn() => 7)()", 7},
+	}
+
+	testVM(t, tests)
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	tests := []testCase{
+		{
+			"let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(4);",
+			10,
+		},
+		{
+			"let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; let g = fn() { f(4) }; g();",
+			10,
+		},
+		{
+			"let factorial = fn(n) { if (n == 0) { 1 } else { n * factorial(n - 1) } }; factorial(5);",
+			120,
+		},
+		{
+			`let wrapper = fn() {
+				let factorial = fn(n) { if (n == 0) { 1 } else { n * factorial(n - 1) } };
+				factorial(5)
+			};
+			wrapper();`,
+			120,
+		},
+	}
+
+	testVM(t, tests)
+}
+
+func TestClosure(t *testing.T) {
+	tests := []testCase{
+		{"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(2)(3)", 5},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); [addTwo(1), addTwo(10)]", []int{3, 12}},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{"let g = 10; let f = fn(a) { let b = a * 2; fn() { g + a + b } }; f(1)()", 13},
+		// Each closure keeps its own captured values
+		{"let k = fn(x) { fn() { x } }; let one = k(1); let two = k(2); [one(), two()]", []int{1, 2}},
+		// Recursive closure referring to a captured value
+		{
+			`let countdown = fn(step) {
+				let loop = fn(n) { if (n <= 0) { 0 } else { 1 + loop(n - step) } };
+				loop
+			};
+			countdown(2)(10)`,
+			5,
+		},
+		{"let outer = fn(x) { let inner = fn() { x }; inner }; outer(7)()", 7},
+	}
+
+	testVM(t, tests)
+}
+
+func TestClosureParity(t *testing.T) {
+	inputs := []string{
+		"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(2)(3)",
+		"let compose = fn(f, g) { fn(x) { g(f(x)) } }; compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5)",
+		"let counter = fn(start) { fn(step) { start + step } }; apply(counter(10), [5])",
+		"let f = fn(x) { fn() { type(x) } }; f([1])()",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestRecursiveParity(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n){ if (n<2){n} else {fib(n-1)+fib(n-2)} }; fib(10)",
+		// Local to a function, so only reachable through the current closure
+		"let wrapper = fn() { let fib = fn(n){ if (n<2){n} else {fib(n-1)+fib(n-2)} }; fib(10) }; wrapper()",
+		// Captures a free variable as well as referring to itself
+		"let scaled = fn(k) { let f = fn(n) { if (n == 0) { 0 } else { k + f(n - 1) } }; f }; scaled(3)(4)",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+
+	testVM(t, []testCase{{"let fib = fn(n){ if (n<2){n} else {fib(n-1)+fib(n-2)} }; fib(10)", 55}})
+}
+
+func TestTailCall(t *testing.T) {
+	tests := []testCase{
+		// Deeper than the frame stack (frameCapacity)
+		{"let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100000, 0)", 5000050000},
+		{`let count = fn(n) { if (n == 0) { return "done"; } return count(n - 1); }; count(50000)`, "done"},
+		// Callee with more parameters and locals than the caller
+		{"let g = fn(a, b) { let c = a * b; c }; let f = fn(x) { g(x, 2) }; 1 + f(20)", 41},
+		{"let f = fn(x) { len(x) }; f([1, 2]) + f([3])", 3},
+		{"let g = fn() { 7 }; let f = fn(x) { let y = x + 1; g() }; [f(1), f(2)]", []int{7, 7}},
+	}
+
+	testVM(t, tests)
+
+	testVMError(t, "let g = fn(a) { a }; let f = fn() { g() }; f()", "Wrong number of arguments. Expected=1, Actual=0")
+	testVMError(t, "let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100000)", "Stack overflow")
+}
+
 func TestBuiltin(t *testing.T) {
 	tests := []testCase{
 		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`let s = "日本語"; ord(s[len(s) - 1])`, 35486},
 		{"len([1,2,3])", 3},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(values({"a": 1, "b": 2}))`, 2},
+		{`ord("A")`, 65},
+		{`help("len")`, "len(x): Number of characters in a string, elements in an array or pairs in a hash"},
+		{"chr(65)", "A"},
+		{`padStart("7", 3, "0")`, "007"},
+		{`padStart(str(42), 5, "0")`, "00042"},
+		{`startsWith("hello", "he")`, true},
+		{`endsWith("hello", "he") == false`, true},
+		{`if (contains("hello", "ll")) { 1 } else { 2 }`, 1},
+		{`match("abc", "b+")`, true},
+		{`replaceRegex("aaa", "a", "b")`, "bbb"},
+		{"let a = [1, 2]; let b = a; same(a, b)", true},
+		{"same([1, 2], [1, 2])", false},
+		{"same(1 + 1, 2)", true},
+		{"floor(-2.5)", -3},
+		{"ceil(2.1)", 3},
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"trunc(-2.9)", -2},
+		{"floor(3)", 3},
+		{"first([1, 2, 3])", 1},
+		{"first([])", Null},
+		{"last([1, 2, 3])", 3},
+		{"last([])", Null},
+		{"let arr = [4, 5]; len(arr) + first(arr) + last(arr)", 11},
 	}
 
 	testVM(t, tests)
+
+	testVMInspect(t, "len(1)", "ERROR: argument to `len` not supported, got INTEGER")
+	testVMInspect(t, `delete({"a": 1}, fn() {})`, "ERROR: unusable as hash key: CLOSURE")
+	testVMInspect(t, "apply(1, [])", "ERROR: first argument to `apply` must be a function, got INTEGER")
+	testVMInspect(t, `padStart("a", 9223372036854775807, " ")`, "ERROR: second argument to `padStart` too large: 9223372036854775807")
+	testVMError(t, "apply(fn(a, b) { a }, [1])", "Wrong number of arguments. Expected=2, Actual=1")
+}
+
+func TestBuiltinParity(t *testing.T) {
+	inputs := []string{
+		`len("hi")`,
+		"len([])",
+		"first([[1], 2])",
+		"last([1, [2, 3]])",
+		"tail([1, 2, 3])",
+		"push([1], 2)",
+		`help("first")`,
+		`delete({"a": 1, "b": 2}, "b")`,
+		`delete({"a": 1}, "z")`,
+		`split("1,2,3", ",")`,
+		`len(split("", ","))`,
+		`join(["x", "y", "z"], "+")`,
+		`join([], "+")`,
+		`slice("hello", 1, -1)`,
+		"slice([1, 2, 3], -2)",
+		"abs(-2.5)",
+		"min(3, 1, 2)",
+		"max(1, 2.5, 2)",
+		"type([1])",
+		"type(fn(x) { x })",
+		"let f = fn(y) { fn(x) { x + y } }; type(f(1))",
+		"type(len)",
+		`int("42") + int(3.9)`,
+		`str(42) + " apples"`,
+		"str([1, true])",
+		"apply(fn(a, b) { a - b }, [10, 3])",
+		"let f = fn(n) { if (n == 0) { 0 } else { apply(f, [n - 1]) + n } }; f(10)",
+		"apply(len, [[1, 2, 3]])",
+		"apply(apply, [fn(x) { x + 1 }, [1]])",
+		"let sub = fn(a, b) { a - b }; let tenMinus = bind(sub, 10); tenMinus(3)",
+		"let add3 = fn(a, b, c) { a + b + c }; bind(bind(add3, 1), 2)(3)",
+		"let k = fn(x) { bind(fn(a, b) { a * b + x }, 2) }; k(1)(5)",
+		"let f = len; f([1, 2])",
+		"len |> fn(f) { f(\"abc\") }",
+	}
+
+	for _, input := range inputs {
+		testParity(t, input)
+	}
+}
+
+func TestConstantFolding(t *testing.T) {
+	inputs := []string{
+		"2 + 3 * 4",
+		"-(7 % 4) - 10 / 2",
+		"-7 / 2",
+		"9223372036854775807 + 1",
+		`"a" + "b" + "c"`,
+		`"ab" * (1 + 1)`,
+		"1.5 + 2 * 3",
+		"let x = 4; x * (2 + 3)",
+		"let f = fn(n) { n + 10 * 10 }; f(1)",
+		"if (1 + 1 == 2) { 3 * 3 } else { 0 }",
+	}
+
+	for _, input := range inputs {
+		results := []string{}
+		for _, folding := range []bool{false, true} {
+			c := compiler.BuildCompiler()
+			c.SetConstantFolding(folding)
+			err := c.Compile(parse(input))
+			if err != nil {
+				t.Fatalf("Compiler error: %s", err)
+			}
+
+			vm := BuildVM(c.Bytecode())
+			err = vm.Run()
+			if err != nil {
+				t.Fatalf("VM error: %s", err)
+			}
+			results = append(results, vm.LastPopped().Inspect())
+		}
+
+		assert.Equal(t, results[0], results[1], input)
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse("let one = 1; one + 2"))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVM(c.Bytecode())
+	for _, keepGlobals := range []bool{true, false} {
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("VM error: %s", err)
+		}
+		testExpectedObject(t, 3, vm.LastPopped())
+
+		vm.Reset(keepGlobals)
+		assert.Equal(t, 0, vm.stackPointer)
+		assert.Equal(t, keepGlobals, len(vm.globals) == 1)
+	}
+}
+
+func TestLazyGlobals(t *testing.T) {
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse("let one = 1; let two = 2; one + two"))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVM(c.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("VM error: %s", err)
+	}
+
+	testExpectedObject(t, 3, vm.LastPopped())
+	assert.Equal(t, 2, len(vm.Globals()))
+	assert.True(t, cap(vm.Globals()) < GlobalCapacity)
+}
+
+func TestStackSize(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(200);"
+
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	small := BuildVMWithOptions(c.Bytecode(), Options{StackSize: 64})
+	err = small.Run()
+	if err == nil {
+		t.Fatalf("Expected stack overflow")
+	}
+	assert.Equal(t, "Stack overflow", err.Error())
+
+	large := BuildVMWithOptions(c.Bytecode(), Options{StackSize: 4096})
+	err = large.Run()
+	if err != nil {
+		t.Fatalf("VM error: %s", err)
+	}
+	testExpectedObject(t, 200, large.LastPopped())
+}
+
+func TestMaxLiteralSize(t *testing.T) {
+	object.MaxLiteralSize = 2
+	defer func() { object.MaxLiteralSize = 0 }()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3]", "Array literal too large: 3 elements (max 2)"},
+		{"{1: 1, 2: 2, 3: 3}", "Hash literal too large: 3 pairs (max 2)"},
+	}
+
+	for _, test := range tests {
+		c := compiler.BuildCompiler()
+		err := c.Compile(parse(test.input))
+		if err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+
+		vm := BuildVM(c.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("Expected VM error")
+		}
+		assert.Equal(t, test.expected, err.Error())
+	}
+
+	testVM(t, []testCase{{"[1, 2][1]", 2}})
+}
+
+func TestStep(t *testing.T) {
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse("1 + 2; 4"))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVM(c.Bytecode())
+
+	// Instruction pointer and stack after each step
+	steps := []struct {
+		ip    int
+		stack string
+	}{
+		{3, "[1]"},    // OpConstant 0
+		{6, "[1, 2]"}, // OpConstant 1
+		{7, "[3]"},    // OpAdd
+		{8, "[]"},     // OpPop
+		{11, "[4]"},   // OpConstant 2
+		{12, "[]"},    // OpPop
+	}
+
+	assert.Equal(t, 0, vm.InstructionPointer())
+
+	for i, step := range steps {
+		done, err := vm.Step()
+		assert.Nil(t, err)
+		assert.Equal(t, i == len(steps)-1, done, "done after step %d", i)
+		assert.Equal(t, step.ip, vm.InstructionPointer(), "ip after step %d", i)
+		assert.Equal(t, step.stack, (&object.Array{Elements: vm.Stack()}).Inspect(), "stack after step %d", i)
+	}
+
+	// Stepping a finished program does nothing
+	done, err := vm.Step()
+	assert.True(t, done)
+	assert.Nil(t, err)
+	testIntegerObject(t, 4, vm.LastPopped())
+}
+
+func TestOpcodeCounts(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(4);"
+
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVMWithOptions(c.Bytecode(), Options{CountOpcodes: true})
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("VM error: %s", err)
+	}
+
+	counts := vm.OpcodeCounts()
+	assert.Equal(t, 4, counts[bytecode.OpAdd])
+	assert.Equal(t, 4, counts[bytecode.OpSub])
+	assert.Equal(t, 5, counts[bytecode.OpCall])
+
+	// Not counting by default
+	assert.True(t, BuildVM(c.Bytecode()).OpcodeCounts() == nil)
+}
+
+func BenchmarkFreshVM(b *testing.B) {
+	c := compiler.BuildCompiler()
+	c.Compile(parse("let x = 1; x + 2"))
+	bytecode := c.Bytecode()
+
+	for i := 0; i < b.N; i++ {
+		vm := BuildVM(bytecode)
+		vm.Run()
+	}
+}
+
+func BenchmarkReusedVM(b *testing.B) {
+	c := compiler.BuildCompiler()
+	c.Compile(parse("let x = 1; x + 2"))
+	vm := BuildVM(c.Bytecode())
+
+	for i := 0; i < b.N; i++ {
+		vm.Run()
+		vm.Reset(true)
+	}
 }
 
 func testVM(t *testing.T, tests []testCase) {
@@ -184,6 +1005,48 @@ func testVM(t *testing.T, tests []testCase) {
 	}
 }
 
+// Helper function to check the inspected result of running input
+func testVMInspect(t *testing.T, input string, expected string) {
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVM(c.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("VM error: %s", err)
+	}
+	assert.Equal(t, expected, vm.LastPopped().Inspect(), input)
+}
+
+// Helper function to check that the VM and the evaluator agree on the result of input
+func testParity(t *testing.T, input string) {
+	expected := evaluator.Eval(parse(input), object.BuildEnvironment())
+	if expected.Type() == object.ERROR_OBJECT {
+		t.Fatalf("Evaluator error for %s: %s", input, expected.Inspect())
+	}
+
+	testVMInspect(t, input, expected.Inspect())
+}
+
+// Helper function to check that running input fails with the expected error
+func testVMError(t *testing.T, input string, expected string) {
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVM(c.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("Expected VM error for %s", input)
+	}
+	assert.Equal(t, expected, err.Error(), input)
+}
+
 func parse(input string) *ast.Program {
 	l := lexer.BuildLexer(input)
 	p := parser.BuildParser(l)
@@ -266,3 +1129,33 @@ func testHashObject(t *testing.T, expected map[object.HashKey]int64, actual obje
 		testIntegerObject(t, expectedValue, pair.Value)
 	}
 }
+
+func TestResult(t *testing.T) {
+	c := compiler.BuildCompiler()
+	err := c.Compile(parse("let double = fn(x) { x * 2 }; double(21)"))
+	if err != nil {
+		t.Fatalf("Compiler error: %s", err)
+	}
+
+	vm := BuildVM(c.Bytecode())
+
+	// No result until the program has run
+	_, err = vm.Result()
+	assert.EqualError(t, err, "Program has not finished running")
+
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("VM error: %s", err)
+	}
+
+	result, err := vm.Result()
+	assert.NoError(t, err)
+	value, err := object.ToGo(result)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, value)
+
+	// Values left on the stack mean the program didn't finish cleanly
+	vm.push(result)
+	_, err = vm.Result()
+	assert.EqualError(t, err, "Program left 1 values on the stack")
+}